@@ -4,23 +4,35 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 
+	"github.com/amarbel-llc/sweatshop/internal/attempts"
+	"github.com/amarbel-llc/sweatshop/internal/claude"
 	"github.com/amarbel-llc/sweatshop/internal/clean"
 	"github.com/amarbel-llc/sweatshop/internal/completions"
 	"github.com/amarbel-llc/sweatshop/internal/executor"
+	"github.com/amarbel-llc/sweatshop/internal/hook"
 	"github.com/amarbel-llc/sweatshop/internal/merge"
 	"github.com/amarbel-llc/sweatshop/internal/perms"
 	"github.com/amarbel-llc/sweatshop/internal/pull"
 	"github.com/amarbel-llc/sweatshop/internal/shop"
+	"github.com/amarbel-llc/sweatshop/internal/spawn"
 	"github.com/amarbel-llc/sweatshop/internal/status"
+	"github.com/amarbel-llc/sweatshop/internal/transcript"
 	"github.com/amarbel-llc/sweatshop/internal/worktree"
 )
 
 var outputFormat string
 var createVerbose bool
+var createPrebuild bool
+var createAgent string
+var attachAgent string
+var attachNest bool
+var attachRecord bool
+var attachResume bool
 
 var rootCmd = &cobra.Command{
 	Use:   "sweatshop",
@@ -49,15 +61,19 @@ var createCmd = &cobra.Command{
 			return err
 		}
 
-		return shop.Create(rp, createVerbose)
+		return shop.Create(rp, shop.CreateOptions{
+			Verbose:  createVerbose,
+			Prebuild: createPrebuild,
+			Agent:    createAgent,
+		})
 	},
 }
 
 var attachCmd = &cobra.Command{
-	Use:     "attach <target> [claude args...]",
+	Use:     "attach <target> [agent args...]",
 	Aliases: []string{"open"},
 	Short:   "Create (if needed) and attach to a worktree session",
-	Long:    `Create a worktree if it doesn't exist, then attach to a session. Target is a branch name or path, resolved relative to the current git repository. If additional arguments are provided, claude is launched with those arguments instead of a shell.`,
+	Long:    `Create a worktree if it doesn't exist, then attach to a session. Target is a branch name or path, resolved relative to the current git repository. Refuses to run inside another sweatshop session unless --nest is given; switching to the target's session from inside another one is not supported, so detach first. If --agent is given or additional arguments are provided, the configured agent (claude by default) is launched with those arguments instead of a shell.`,
 	Args:    cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		format := outputFormat
@@ -67,9 +83,9 @@ var attachCmd = &cobra.Command{
 
 		exec := executor.ShellExecutor{}
 
-		var claudeArgs []string
+		var agentArgs []string
 		if len(args) >= 2 {
-			claudeArgs = args[1:]
+			agentArgs = args[1:]
 		}
 
 		cwd, err := os.Getwd()
@@ -87,7 +103,230 @@ var attachCmd = &cobra.Command{
 			return err
 		}
 
-		return shop.Attach(exec, rp, format, claudeArgs)
+		return shop.Attach(exec, rp, format, shop.AttachOptions{
+			Agent:     attachAgent,
+			AgentArgs: agentArgs,
+			Nest:      attachNest,
+			Record:    attachRecord,
+			Resume:    attachResume,
+		})
+	},
+}
+
+var runAgent string
+var runPrompt string
+
+var runCmd = &cobra.Command{
+	Use:   "run <target> --prompt <prompt>",
+	Short: "Run an agent headlessly in a worktree",
+	Long:  `Create a worktree if it doesn't exist, then run the configured agent non-interactively with the given prompt. Output is logged under the worktree's git admin dir along with the exit status and duration. Prints the same close-shop summary as attach when the agent finishes.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := outputFormat
+		if format == "" {
+			format = "tap"
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		rp, err := worktree.ResolvePath(repoPath, args[0])
+		if err != nil {
+			return err
+		}
+
+		return shop.Run(rp, format, shop.RunOptions{
+			Agent:  runAgent,
+			Prompt: runPrompt,
+		})
+	},
+}
+
+var spawnJobs int
+var spawnSession bool
+
+var spawnCmd = &cobra.Command{
+	Use:   "spawn <tasks.toml>",
+	Short: "Run many agent tasks in their own worktrees",
+	Long:  `Read [[task]] entries (branch, prompt, and optionally agent and base) from a TOML task file, create one worktree per task, and run each agent headlessly with at most --jobs at once. Use --session to start every task in its own zmx session at once instead. Branches must be bare names. Progress is reported as TAP.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tasks, err := spawn.Load(args[0])
+		if err != nil {
+			return fmt.Errorf("loading task file: %w", err)
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		if spawnSession && cmd.Flags().Changed("jobs") {
+			return fmt.Errorf("--jobs has no effect with --session, which starts every task at once")
+		}
+
+		opts := spawn.Options{Jobs: spawnJobs}
+		if spawnSession {
+			opts.Spawner = executor.ZmxExecutor{}
+		}
+
+		return spawn.Run(repoPath, tasks, os.Stdout, opts)
+	},
+}
+
+var attemptsCount int
+var attemptsPrompt string
+var attemptsAgent string
+var attemptsBase string
+var attemptsJobs int
+
+var attemptsCmd = &cobra.Command{
+	Use:   "attempts <name> -n <count> --prompt <prompt>",
+	Short: "Run the same prompt in several sibling worktrees",
+	Long:  `Create sibling worktrees <name>-1 through <name>-N from the same base commit and run the agent headlessly with the same prompt in each. Use compare to review the results and pick to keep the best one.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		return attempts.Run(repoPath, args[0], os.Stdout, attempts.Options{
+			Count:  attemptsCount,
+			Prompt: attemptsPrompt,
+			Agent:  attemptsAgent,
+			Base:   attemptsBase,
+			Jobs:   attemptsJobs,
+		})
+	},
+}
+
+var compareTest string
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <name>",
+	Short: "Compare the attempts of a task side by side",
+	Long:  `Show commit counts, diffstats against the commit the attempts started from, and the last headless run of every <name>-N attempt. Use --test to run a test command in each attempt and include its result.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		format := outputFormat
+		if format == "" {
+			format = "table"
+		}
+
+		results, err := attempts.Compare(repoPath, args[0], compareTest)
+		if err != nil {
+			return err
+		}
+
+		if format == "tap" {
+			attempts.RenderTap(results, os.Stdout)
+		} else {
+			fmt.Println(attempts.Render(results))
+		}
+		return nil
+	},
+}
+
+var pickCmd = &cobra.Command{
+	Use:   "pick <winner>",
+	Short: "Keep one attempt and remove the rest",
+	Long:  `Given an attempt such as fix-2, remove every other fix-N attempt (worktree and branch, even if unmerged) and rename the winner's branch and worktree to fix.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		return attempts.Pick(repoPath, args[0], os.Stdout)
+	},
+}
+
+var hookCmd = &cobra.Command{
+	Use:    "hook <event>",
+	Short:  "Handle a Claude Code hook event",
+	Long:   `Read a Claude Code hook payload from stdin and record the conversation it belongs to in the worktree's state, for attach --resume and status. Installed into .claude/settings.local.json when a worktree is created.`,
+	Args:   cobra.ExactArgs(1),
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return hook.Handle(args[0], os.Stdin)
+	},
+}
+
+var replaySpeed float64
+var replayMaxIdle time.Duration
+var replayList bool
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <target> [transcript]",
+	Short: "Play back a recorded session",
+	Long:  `Play back a session transcript recorded by attach --record (or record = true in the sweatfile). Defaults to the most recent transcript of the target worktree; --list shows all of them, including those archived when the worktree was cleaned. Transcripts are asciicast v2 files and also play in asciinema.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoPath, err := worktree.DetectRepo(cwd)
+		if err != nil {
+			return err
+		}
+
+		rp, err := worktree.ResolvePath(repoPath, args[0])
+		if err != nil {
+			return err
+		}
+
+		if replayList {
+			for _, p := range shop.Transcripts(rp) {
+				fmt.Println(p)
+			}
+			return nil
+		}
+
+		var name string
+		if len(args) == 2 {
+			name = args[1]
+		}
+
+		return shop.Replay(rp, name, transcript.ReplayOptions{
+			Speed:   replaySpeed,
+			MaxIdle: replayMaxIdle,
+		})
 	},
 }
 
@@ -124,7 +363,7 @@ var statusCmd = &cobra.Command{
 var mergeCmd = &cobra.Command{
 	Use:   "merge",
 	Short: "Merge current worktree into main",
-	Long:  `Run from inside a worktree. Merges the worktree branch into the main repo with --ff-only and removes the worktree.`,
+	Long:  `Run from inside a worktree. Merges the worktree branch into the main repo with --ff-only and removes the worktree. Detaches only when run in the worktree's own sweatshop session; sessions started by older versions, which are not marked, are left attached.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return merge.Run(executor.ShellExecutor{})
 	},
@@ -185,9 +424,37 @@ var completionsCmd = &cobra.Command{
 func init() {
 	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "", "output format: tap or table")
 	createCmd.Flags().BoolVarP(&createVerbose, "verbose", "v", false, "print sweatfile loading details")
+	createCmd.Flags().BoolVar(&createPrebuild, "prebuild", false, "build the dev environment so the first attach is instant")
+	createCmd.Flags().StringVar(&createAgent, "agent", "", "agent profile from the sweatfile to set up the worktree for")
+	attachCmd.Flags().StringVar(&attachAgent, "agent", "", "agent profile from the sweatfile to launch")
+	attachCmd.Flags().BoolVar(&attachNest, "nest", false, "allow attaching from inside another sweatshop session")
+	attachCmd.Flags().BoolVar(&attachRecord, "record", false, "record the session to a transcript for replay")
+	attachCmd.Flags().BoolVar(&attachResume, "resume", false, "continue the agent's last conversation in this worktree")
 	cleanCmd.Flags().BoolVarP(&cleanInteractive, "interactive", "i", false, "interactively discard changes in dirty merged worktrees")
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(attachCmd)
+	runCmd.Flags().StringVar(&runAgent, "agent", "", "agent profile from the sweatfile to run")
+	runCmd.Flags().StringVarP(&runPrompt, "prompt", "p", "", "prompt to pass to the agent")
+	runCmd.MarkFlagRequired("prompt")
+	rootCmd.AddCommand(runCmd)
+	spawnCmd.Flags().IntVarP(&spawnJobs, "jobs", "j", 4, "maximum number of headless agents running at once")
+	spawnCmd.Flags().BoolVar(&spawnSession, "session", false, "start each task in its own zmx session instead of headless")
+	rootCmd.AddCommand(spawnCmd)
+	attemptsCmd.Flags().IntVarP(&attemptsCount, "count", "n", 3, "number of attempts")
+	attemptsCmd.Flags().StringVarP(&attemptsPrompt, "prompt", "p", "", "prompt to pass to every attempt")
+	attemptsCmd.Flags().StringVar(&attemptsAgent, "agent", "", "agent profile from the sweatfile to run")
+	attemptsCmd.Flags().StringVar(&attemptsBase, "base", "", "ref all attempts start from (default HEAD)")
+	attemptsCmd.Flags().IntVarP(&attemptsJobs, "jobs", "j", 0, "maximum number of attempts running at once (default all)")
+	attemptsCmd.MarkFlagRequired("prompt")
+	rootCmd.AddCommand(attemptsCmd)
+	compareCmd.Flags().StringVar(&compareTest, "test", "", "shell command to run in each attempt, e.g. 'go test ./...'")
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(pickCmd)
+	replayCmd.Flags().Float64VarP(&replaySpeed, "speed", "s", 1, "playback speed multiplier")
+	replayCmd.Flags().DurationVar(&replayMaxIdle, "max-idle", 0, "cap pauses between events, e.g. 2s")
+	replayCmd.Flags().BoolVar(&replayList, "list", false, "list transcripts instead of playing one")
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(hookCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(mergeCmd)
 	rootCmd.AddCommand(cleanCmd)
@@ -199,6 +466,10 @@ func init() {
 
 func main() {
 	rootCmd.Use = filepath.Base(os.Args[0])
+	// Hooks run from Claude's environment, whose PATH need not find us.
+	if exe, err := perms.CurrentExecutable(); err == nil {
+		claude.HookProgram = exe
+	}
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
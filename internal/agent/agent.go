@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
+)
+
+// DefaultCommand is launched when the sweatfile does not configure [agent].
+const DefaultCommand = "claude"
+
+// defaultClaudePromptArgs runs claude non-interactively in print mode.
+var defaultClaudePromptArgs = []string{"-p", "{{.Prompt}}"}
+
+// defaultClaudeResumeArgs continue a claude conversation by id.
+var defaultClaudeResumeArgs = []string{"--resume", "{{.SessionID}}"}
+
+// Agent is a resolved agent profile ready to build a command line.
+type Agent struct {
+	Name    string
+	Command string
+	Args    []string
+	Env     map[string]string
+	// PromptArgs are appended for headless runs. When nil the prompt is
+	// passed as the final argument.
+	PromptArgs []string
+	// ResumeArgs are appended to continue a previous conversation. When
+	// nil the agent cannot resume.
+	ResumeArgs []string
+	// Claude is true when the agent needs Claude Code workspace trust and
+	// settings.local.json written on worktree creation.
+	Claude bool
+}
+
+// Context is the data available to args and env templates, e.g.
+// {{.Worktree}} or {{.Branch}}.
+type Context struct {
+	Worktree   string
+	Repo       string
+	Branch     string
+	SessionKey string
+	Prompt     string
+	// SessionID is the agent's own conversation id, set when resuming.
+	SessionID string
+}
+
+// Resolve picks the agent profile called name from sf. An empty name selects
+// the [agent] section, falling back to plain claude when it sets no command.
+func Resolve(sf sweatfile.Sweatfile, name string) (Agent, error) {
+	cfg := sf.Agent
+	if name != "" {
+		var ok bool
+		cfg, ok = sf.Agents[name]
+		if !ok {
+			return Agent{}, fmt.Errorf("unknown agent %q", name)
+		}
+		if cfg.Command == "" {
+			return Agent{}, fmt.Errorf("agent %q has no command", name)
+		}
+	}
+
+	if cfg.Command == "" {
+		cfg.Command = DefaultCommand
+	}
+
+	isClaude := filepath.Base(cfg.Command) == DefaultCommand
+	promptArgs := cfg.PromptArgs
+	if promptArgs == nil && isClaude {
+		promptArgs = defaultClaudePromptArgs
+	}
+	resumeArgs := cfg.ResumeArgs
+	if resumeArgs == nil && isClaude {
+		resumeArgs = defaultClaudeResumeArgs
+	}
+
+	claude := isClaude
+	if cfg.Claude != nil {
+		claude = *cfg.Claude
+	}
+
+	return Agent{
+		Name:       name,
+		Command:    cfg.Command,
+		Args:       cfg.Args,
+		Env:        cfg.Env,
+		PromptArgs: promptArgs,
+		ResumeArgs: resumeArgs,
+		Claude:     claude,
+	}, nil
+}
+
+// BuildPromptCommand is BuildCommand for a headless run of prompt, with the
+// agent's prompt args appended.
+func (a Agent) BuildPromptCommand(ctx Context, prompt string) ([]string, error) {
+	ctx.Prompt = prompt
+
+	promptArgs := a.PromptArgs
+	if promptArgs == nil {
+		promptArgs = []string{"{{.Prompt}}"}
+	}
+
+	var extra []string
+	for _, arg := range promptArgs {
+		v, err := render(arg, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rendering prompt arg %q: %w", arg, err)
+		}
+		extra = append(extra, v)
+	}
+
+	return a.BuildCommand(ctx, extra)
+}
+
+// BuildResumeCommand is BuildCommand continuing the conversation sessionID,
+// with the agent's resume args placed before extraArgs.
+func (a Agent) BuildResumeCommand(ctx Context, sessionID string, extraArgs []string) ([]string, error) {
+	if a.ResumeArgs == nil {
+		return nil, fmt.Errorf("agent %s has no resume_args", a.Command)
+	}
+	ctx.SessionID = sessionID
+
+	var args []string
+	for _, arg := range a.ResumeArgs {
+		v, err := render(arg, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rendering resume arg %q: %w", arg, err)
+		}
+		args = append(args, v)
+	}
+
+	return a.BuildCommand(ctx, append(args, extraArgs...))
+}
+
+// BuildCommand renders the agent's args and env against ctx and returns the
+// full command line with extraArgs appended. Env entries are applied through
+// env(1) so they survive any executor or dev environment wrapping.
+func (a Agent) BuildCommand(ctx Context, extraArgs []string) ([]string, error) {
+	var command []string
+
+	if len(a.Env) > 0 {
+		keys := make([]string, 0, len(a.Env))
+		for k := range a.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		command = append(command, "env")
+		for _, k := range keys {
+			v, err := render(a.Env[k], ctx)
+			if err != nil {
+				return nil, fmt.Errorf("rendering env %s: %w", k, err)
+			}
+			command = append(command, k+"="+v)
+		}
+	}
+
+	command = append(command, a.Command)
+	for _, arg := range a.Args {
+		v, err := render(arg, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rendering arg %q: %w", arg, err)
+		}
+		command = append(command, v)
+	}
+
+	return append(command, extraArgs...), nil
+}
+
+func render(text string, ctx Context) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("agent").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, ctx); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
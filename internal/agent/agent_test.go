@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
+)
+
+func TestResolveDefault(t *testing.T) {
+	a, err := Resolve(sweatfile.Sweatfile{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Command != "claude" {
+		t.Errorf("Command = %q, want claude", a.Command)
+	}
+	if !a.Claude {
+		t.Error("expected default agent to need claude setup")
+	}
+}
+
+func TestResolveNamedProfile(t *testing.T) {
+	sf := sweatfile.Sweatfile{
+		Agents: map[string]sweatfile.Agent{
+			"codex": {Command: "codex", Args: []string{"--full-auto"}},
+		},
+	}
+
+	a, err := Resolve(sf, "codex")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Command != "codex" || a.Name != "codex" {
+		t.Errorf("unexpected agent %+v", a)
+	}
+	if a.Claude {
+		t.Error("expected non-claude agent to skip claude setup")
+	}
+
+	if _, err := Resolve(sf, "aider"); err == nil {
+		t.Error("expected error for unknown agent")
+	}
+}
+
+func TestResolveClaudeOverride(t *testing.T) {
+	yes := true
+	sf := sweatfile.Sweatfile{
+		Agent: sweatfile.Agent{Command: "/usr/local/bin/claude-wrapper", Claude: &yes},
+	}
+
+	a, err := Resolve(sf, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !a.Claude {
+		t.Error("expected explicit claude = true to be honored")
+	}
+}
+
+func TestBuildCommand(t *testing.T) {
+	a := Agent{
+		Command: "claude",
+		Args:    []string{"--add-dir", "{{.Worktree}}"},
+		Env: map[string]string{
+			"SESSION": "{{.SessionKey}}",
+			"BRANCH":  "{{.Branch}}",
+		},
+	}
+	ctx := Context{Worktree: "/repo/.worktrees/feat", Branch: "feat", SessionKey: "repo/feat"}
+
+	got, err := a.BuildCommand(ctx, []string{"--continue"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"env", "BRANCH=feat", "SESSION=repo/feat",
+		"claude", "--add-dir", "/repo/.worktrees/feat", "--continue",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildCommandBadTemplate(t *testing.T) {
+	a := Agent{Command: "claude", Args: []string{"{{.Nope}}"}}
+	if _, err := a.BuildCommand(Context{}, nil); err == nil {
+		t.Error("expected error for unknown template field")
+	}
+}
+
+func TestBuildPromptCommand(t *testing.T) {
+	a, err := Resolve(sweatfile.Sweatfile{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := a.BuildPromptCommand(Context{}, "fix {{the}} tests")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"claude", "-p", "fix {{the}} tests"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildPromptCommand() = %q, want %q", got, want)
+	}
+
+	custom := Agent{Command: "codex", Args: []string{"exec"}}
+	got, err = custom.BuildPromptCommand(Context{}, "fix the tests")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{"codex", "exec", "fix the tests"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildPromptCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildResumeCommand(t *testing.T) {
+	a, err := Resolve(sweatfile.Sweatfile{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := a.BuildResumeCommand(Context{}, "abc-123", []string{"--verbose"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"claude", "--resume", "abc-123", "--verbose"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildResumeCommand() = %q, want %q", got, want)
+	}
+
+	codex := Agent{Command: "codex"}
+	if _, err := codex.BuildResumeCommand(Context{}, "abc-123", nil); err == nil {
+		t.Error("expected error for agent without resume_args")
+	}
+}
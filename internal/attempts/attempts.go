@@ -0,0 +1,457 @@
+package attempts
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/amarbel-llc/sweatshop/internal/claude"
+	"github.com/amarbel-llc/sweatshop/internal/clean"
+	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/perms"
+	"github.com/amarbel-llc/sweatshop/internal/ports"
+	"github.com/amarbel-llc/sweatshop/internal/shop"
+	"github.com/amarbel-llc/sweatshop/internal/spawn"
+	"github.com/amarbel-llc/sweatshop/internal/tap"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// Name returns the branch name of attempt n of name, e.g. "fix-1".
+func Name(name string, n int) string {
+	return fmt.Sprintf("%s-%d", name, n)
+}
+
+// Split parses an attempt branch like "fix-2" into ("fix", 2).
+func Split(branch string) (string, int, bool) {
+	idx := strings.LastIndex(branch, "-")
+	if idx <= 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(branch[idx+1:])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return branch[:idx], n, true
+}
+
+// Siblings returns the worktree paths of all attempts of name in repoPath,
+// ordered by attempt number.
+func Siblings(repoPath, name string) []string {
+	re := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-(\d+)$`)
+
+	type sibling struct {
+		n    int
+		path string
+	}
+	var found []sibling
+	for _, wtPath := range worktree.ListWorktrees(repoPath) {
+		m := re.FindStringSubmatch(filepath.Base(wtPath))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		found = append(found, sibling{n, wtPath})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].n < found[j].n })
+
+	paths := make([]string, len(found))
+	for i, s := range found {
+		paths[i] = s.path
+	}
+	return paths
+}
+
+type Options struct {
+	Count  int
+	Prompt string
+	Agent  string
+	// Base is the ref every attempt starts from. Empty uses HEAD. It is
+	// resolved to a commit once so all attempts share the same starting point.
+	Base string
+	Jobs int
+}
+
+// Run creates opts.Count sibling worktrees <name>-1..N from the same base
+// commit and runs the prompt headlessly in each, reporting TAP to w.
+func Run(repoPath, name string, w io.Writer, opts Options) error {
+	if opts.Count < 1 {
+		return fmt.Errorf("attempt count must be at least 1")
+	}
+
+	base := opts.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	commit, err := git.RevParse(repoPath, base)
+	if err != nil {
+		return fmt.Errorf("resolving base %s: %w", base, err)
+	}
+
+	tasks := make([]spawn.Task, opts.Count)
+	for i := range tasks {
+		tasks[i] = spawn.Task{
+			Branch: Name(name, i+1),
+			Prompt: opts.Prompt,
+			Agent:  opts.Agent,
+			Base:   commit,
+		}
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = opts.Count
+	}
+
+	runErr := spawn.Run(repoPath, tasks, w, spawn.Options{Jobs: jobs})
+
+	// Compare diffs each attempt against where it started rather than the
+	// default branch, which --base need not be on.
+	for _, t := range tasks {
+		rp, err := worktree.ResolvePath(repoPath, t.Branch)
+		if err != nil || !worktree.IsWorktree(rp.AbsPath) {
+			continue
+		}
+		if err := saveBase(rp.AbsPath, commit); err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+	return runErr
+}
+
+// BaseFileName holds the commit an attempt started from, inside its
+// worktree state dir.
+const BaseFileName = "attempt-base"
+
+func saveBase(wtPath, commit string) error {
+	dir, err := worktree.StateDir(wtPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, BaseFileName), []byte(commit+"\n"), 0o644)
+}
+
+// loadBase returns the commit the attempt at wtPath started from, or false
+// for attempts created before it was recorded.
+func loadBase(wtPath string) (string, bool) {
+	dir, err := worktree.StateDir(wtPath)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, BaseFileName))
+	if err != nil {
+		return "", false
+	}
+	base := strings.TrimSpace(string(data))
+	return base, base != ""
+}
+
+// Result is one attempt's row in a comparison.
+type Result struct {
+	Branch   string
+	Commits  int
+	Diffstat string
+	// Tests is "pass", "fail" or "" when no test command was given.
+	Tests      string
+	TestOutput string
+	Run        string
+}
+
+// Compare collects diffstats, commit counts and last run results for every
+// attempt of name, against the commit the attempt started from, or the
+// default branch when that was not recorded. When testCmd is set it is run through sh -c in each
+// attempt, one at a time so that tests do not contend for ports or caches.
+func Compare(repoPath, name, testCmd string) ([]Result, error) {
+	siblings := Siblings(repoPath, name)
+	if len(siblings) == 0 {
+		return nil, fmt.Errorf("no attempts of %s found", name)
+	}
+
+	var results []Result
+	for _, wtPath := range siblings {
+		branch := filepath.Base(wtPath)
+		base, ok := loadBase(wtPath)
+		if !ok {
+			defaultBranch, err := git.DefaultBranch(repoPath)
+			if err != nil || defaultBranch == "" {
+				return nil, fmt.Errorf("could not determine default branch")
+			}
+			base = defaultBranch
+		}
+		r := Result{
+			Branch:   branch,
+			Commits:  git.CommitsAhead(wtPath, base, branch),
+			Diffstat: compactShortstat(git.DiffShortstat(wtPath, base, branch)),
+		}
+
+		if record, ok := shop.LastRun(wtPath); ok {
+			duration := time.Duration(record.Duration * float64(time.Second)).Round(time.Second)
+			r.Run = fmt.Sprintf("exit %d in %s", record.ExitCode, duration)
+		}
+
+		if testCmd != "" {
+			cmd := exec.Command("sh", "-c", testCmd)
+			cmd.Dir = wtPath
+			out, err := cmd.CombinedOutput()
+			r.TestOutput = string(out)
+			if err != nil {
+				r.Tests = "fail"
+			} else {
+				r.Tests = "pass"
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+var reShortstat = regexp.MustCompile(`(\d+) (file|insertion|deletion)`)
+
+// compactShortstat turns git's --shortstat line into "3 files +10 -2".
+func compactShortstat(s string) string {
+	if s == "" {
+		return "no changes"
+	}
+
+	files, ins, del := "0", "0", "0"
+	for _, m := range reShortstat.FindAllStringSubmatch(s, -1) {
+		switch m[2] {
+		case "file":
+			files = m[1]
+		case "insertion":
+			ins = m[1]
+		case "deletion":
+			del = m[1]
+		}
+	}
+
+	unit := "files"
+	if files == "1" {
+		unit = "file"
+	}
+	return fmt.Sprintf("%s %s +%s -%s", files, unit, ins, del)
+}
+
+func Render(results []Result) string {
+	headers := []string{"Attempt", "Commits", "Diff", "Tests", "Run"}
+
+	var rows [][]string
+	for _, r := range results {
+		tests := r.Tests
+		if tests == "" {
+			tests = "-"
+		}
+		run := r.Run
+		if run == "" {
+			run = "-"
+		}
+		rows = append(rows, []string{r.Branch, strconv.Itoa(r.Commits), r.Diffstat, tests, run})
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("15"))).
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			base := lipgloss.NewStyle().PaddingLeft(1).PaddingRight(1)
+
+			if row == table.HeaderRow {
+				return base.Bold(true)
+			}
+
+			if col == 3 {
+				switch rows[row][col] {
+				case "pass":
+					return base.Foreground(lipgloss.Color("2"))
+				case "fail":
+					return base.Foreground(lipgloss.Color("1"))
+				}
+			}
+
+			return base
+		})
+
+	return t.Render()
+}
+
+func RenderTap(results []Result, w io.Writer) {
+	tw := tap.NewWriter(w)
+	for _, r := range results {
+		commits := fmt.Sprintf("%d commits", r.Commits)
+		if r.Commits == 1 {
+			commits = "1 commit"
+		}
+		desc := fmt.Sprintf("%s # %s, %s", r.Branch, commits, r.Diffstat)
+		if r.Run != "" {
+			desc += ", " + r.Run
+		}
+		if r.Tests == "fail" {
+			diag := map[string]string{
+				"message":  "tests failed",
+				"severity": "fail",
+			}
+			if r.TestOutput != "" {
+				diag["output"] = r.TestOutput
+			}
+			tw.NotOk(desc, diag)
+			continue
+		}
+		tw.Ok(desc)
+	}
+	tw.Plan()
+}
+
+// Pick keeps winner (an attempt branch like "fix-2") by renaming its branch
+// and worktree to the bare name ("fix"), then force-removes every other
+// attempt through the clean path and drops its Claude trust. Nothing is
+// removed unless the rename succeeds, and a rename that fails partway is
+// undone. Progress is reported to w as TAP.
+func Pick(repoPath, winner string, w io.Writer) error {
+	name, _, ok := Split(winner)
+	if !ok {
+		return fmt.Errorf("%s is not an attempt branch (want <name>-<n>)", winner)
+	}
+
+	siblings := Siblings(repoPath, name)
+	winnerPath := ""
+	for _, p := range siblings {
+		if filepath.Base(p) == winner {
+			winnerPath = p
+		}
+	}
+	if winnerPath == "" {
+		return fmt.Errorf("attempt %s not found", winner)
+	}
+
+	targetPath := filepath.Join(filepath.Dir(winnerPath), name)
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("worktree %s already exists", targetPath)
+	}
+	if _, err := git.RevParse(repoPath, "refs/heads/"+name); err == nil {
+		return fmt.Errorf("branch %s already exists", name)
+	}
+
+	tw := tap.NewWriter(w)
+
+	desc := "rename " + winner + " to " + name
+	if err := rename(repoPath, winnerPath, targetPath, winner, name); err != nil {
+		tw.NotOk(desc, map[string]string{
+			"message":  err.Error(),
+			"severity": "fail",
+		})
+		tw.Plan()
+		return err
+	}
+	tw.Ok(desc)
+
+	var failed bool
+	for _, p := range siblings {
+		if p == winnerPath {
+			continue
+		}
+		branch := filepath.Base(p)
+		if err := discard(repoPath, p, branch); err != nil {
+			failed = true
+			tw.NotOk("remove "+branch, map[string]string{
+				"message":  err.Error(),
+				"severity": "fail",
+			})
+			continue
+		}
+		tw.Ok("remove " + branch)
+	}
+	tw.Plan()
+
+	if failed {
+		return fmt.Errorf("one or more attempts could not be removed")
+	}
+	return nil
+}
+
+// discard removes a losing attempt and its Claude trust, so that its path is
+// not left trusted for whatever is created there later.
+func discard(repoPath, path, branch string) error {
+	if err := clean.Remove(repoPath, path, branch, true); err != nil {
+		return err
+	}
+	claudeJSONPath, err := claudeJSONPath()
+	if err != nil {
+		return err
+	}
+	return claude.UntrustWorkspaces(claudeJSONPath, path)
+}
+
+// rename moves the attempt's branch, worktree, ports and Claude settings and
+// trust from the attempt's name to the bare one. When a step fails, the
+// steps before it are undone in reverse order.
+func rename(repoPath, fromPath, toPath, fromBranch, toBranch string) error {
+	claudeJSONPath, err := claudeJSONPath()
+	if err != nil {
+		return err
+	}
+	// Claude settings scope edits to the worktree path, and trust is keyed
+	// by it, so both are carried over to the new location.
+	settingsPath := filepath.Join(toPath, ".claude", "settings.local.json")
+
+	steps := []struct {
+		do, undo func() error
+	}{
+		{
+			func() error { return git.BranchRename(repoPath, fromBranch, toBranch) },
+			func() error { return git.BranchRename(repoPath, toBranch, fromBranch) },
+		},
+		{
+			func() error { return git.WorktreeMove(repoPath, fromPath, toPath) },
+			func() error { return git.WorktreeMove(repoPath, toPath, fromPath) },
+		},
+		{
+			func() error { return ports.Move(fromPath, toPath) },
+			func() error { return ports.Move(toPath, fromPath) },
+		},
+		{
+			func() error { return perms.RelocateClaudeSettings(settingsPath, fromPath, toPath) },
+			func() error { return perms.RelocateClaudeSettings(settingsPath, toPath, fromPath) },
+		},
+		{
+			func() error { return claude.TrustWorkspace(claudeJSONPath, toPath) },
+			func() error { return claude.UntrustWorkspaces(claudeJSONPath, toPath) },
+		},
+		{
+			func() error { return claude.UntrustWorkspaces(claudeJSONPath, fromPath) },
+			nil,
+		},
+	}
+
+	for i, step := range steps {
+		if err := step.do(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if undoErr := steps[j].undo(); undoErr != nil {
+					return fmt.Errorf("%w (undoing the rename also failed: %v)", err, undoErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func claudeJSONPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude.json"), nil
+}
@@ -0,0 +1,161 @@
+package attempts
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		branch string
+		name   string
+		n      int
+		ok     bool
+	}{
+		{"fix-login-2", "fix-login", 2, true},
+		{"fix-10", "fix", 10, true},
+		{"fix", "", 0, false},
+		{"fix-a", "", 0, false},
+		{"fix-0", "", 0, false},
+		{"-1", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			name, n, ok := Split(tt.branch)
+			if name != tt.name || n != tt.n || ok != tt.ok {
+				t.Errorf("Split(%q) = %q, %d, %v; want %q, %d, %v", tt.branch, name, n, ok, tt.name, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, b := range []string{"fix-10", "fix-2", "fix-1", "fix-other", "fixup-1", "fix"} {
+		dir := filepath.Join(repoDir, worktree.WorktreesDir, b)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := Siblings(repoDir, "fix")
+	want := []string{"fix-1", "fix-2", "fix-10"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d siblings, got %v", len(want), got)
+	}
+	for i, w := range want {
+		if filepath.Base(got[i]) != w {
+			t.Errorf("sibling %d = %q, want %q", i, filepath.Base(got[i]), w)
+		}
+	}
+}
+
+func TestCompactShortstat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "no changes"},
+		{" 3 files changed, 10 insertions(+), 2 deletions(-)", "3 files +10 -2"},
+		{" 1 file changed, 1 insertion(+)", "1 file +1 -0"},
+		{" 2 files changed, 4 deletions(-)", "2 files +0 -4"},
+	}
+
+	for _, tt := range tests {
+		if got := compactShortstat(tt.in); got != tt.want {
+			t.Errorf("compactShortstat(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// attemptsRepo returns a repo with attempts fix-1 and fix-2 of fix, both
+// started from a base commit off the default branch.
+func attemptsRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SWEATSHOP_STATE_DIR", t.TempDir())
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(dir string, args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	run(repo, "init", "-q", "-b", "main")
+	run(repo, "commit", "-q", "--allow-empty", "-m", "init")
+	run(repo, "checkout", "-q", "-b", "base")
+	os.WriteFile(filepath.Join(repo, "base.txt"), []byte("base\n"), 0o644)
+	run(repo, "add", ".")
+	run(repo, "commit", "-q", "-m", "base")
+	run(repo, "checkout", "-q", "main")
+
+	for _, branch := range []string{"fix-1", "fix-2"} {
+		wt := filepath.Join(repo, worktree.WorktreesDir, branch)
+		run(repo, "worktree", "add", "-q", "-b", branch, wt, "base")
+		os.WriteFile(filepath.Join(wt, branch+".txt"), []byte(branch+"\n"), 0o644)
+		run(wt, "add", ".")
+		run(wt, "commit", "-q", "-m", branch)
+		if err := saveBase(wt, "base"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestCompareAgainstRecordedBase(t *testing.T) {
+	repo := attemptsRepo(t)
+
+	results, err := Compare(repo, "fix", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range results {
+		if r.Commits != 1 || r.Diffstat != "1 file +1 -0" {
+			t.Errorf("%s: expected only the attempt's own commit, got %+v", r.Branch, r)
+		}
+	}
+}
+
+func TestPickRefusesExistingBranch(t *testing.T) {
+	repo := attemptsRepo(t)
+	if out, err := exec.Command("git", "-C", repo, "branch", "fix").CombinedOutput(); err != nil {
+		t.Fatalf("git branch: %v: %s", err, out)
+	}
+
+	err := Pick(repo, "fix-2", io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "branch fix already exists") {
+		t.Fatalf("expected an existing branch error, got %v", err)
+	}
+	if got := len(Siblings(repo, "fix")); got != 2 {
+		t.Errorf("expected both attempts to be kept, got %d", got)
+	}
+}
+
+func TestPick(t *testing.T) {
+	repo := attemptsRepo(t)
+
+	if err := Pick(repo, "fix-2", io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Siblings(repo, "fix"); len(got) != 0 {
+		t.Errorf("expected no attempts left, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, worktree.WorktreesDir, "fix", "fix-2.txt")); err != nil {
+		t.Errorf("expected the winner at the bare name: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/amarbel-llc/sweatshop/internal/shellquote"
+)
+
+// HookProgram is the command that hook entries invoke. main sets it to the
+// resolved path of the running binary.
+var HookProgram = "sweatshop"
+
+// SessionHookEvents are the Claude Code hook events handled by
+// "sweatshop hook" to track each worktree's conversation and activity.
+var SessionHookEvents = []string{"SessionStart", "UserPromptSubmit", "PostToolUse", "Notification", "Stop", "SessionEnd"}
+
+// HookCommand returns the command line a hook for event runs, quoted for
+// the shell Claude runs hooks with.
+func HookCommand(event string) string {
+	return shellquote.Join([]string{HookProgram, "hook", event})
+}
+
+// SessionHooks maps each of SessionHookEvents to its hook command.
+func SessionHooks() map[string]string {
+	hooks := make(map[string]string, len(SessionHookEvents))
+	for _, event := range SessionHookEvents {
+		hooks[event] = HookCommand(event)
+	}
+	return hooks
+}
+
+// AddHook registers command as a hook for event in a Claude settings
+// document, leaving other hooks alone. It reports whether doc changed, which
+// it does not when the command is already registered for event.
+func AddHook(doc map[string]any, event, command string) bool {
+	if HasHook(doc, event, command) {
+		return false
+	}
+
+	hooks, _ := doc["hooks"].(map[string]any)
+	if hooks == nil {
+		hooks = make(map[string]any)
+	}
+
+	matchers, _ := hooks[event].([]any)
+	matchers = append(matchers, map[string]any{
+		"hooks": []any{
+			map[string]any{"type": "command", "command": command},
+		},
+	})
+	hooks[event] = matchers
+	doc["hooks"] = hooks
+	return true
+}
+
+// HasHook reports whether command is registered as a hook for event.
+func HasHook(doc map[string]any, event, command string) bool {
+	hooks, _ := doc["hooks"].(map[string]any)
+	matchers, _ := hooks[event].([]any)
+	for _, m := range matchers {
+		matcher, _ := m.(map[string]any)
+		entries, _ := matcher["hooks"].([]any)
+		for _, e := range entries {
+			entry, _ := e.(map[string]any)
+			if entry["command"] == command {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HookCommands returns every command registered as a hook for event.
+func HookCommands(doc map[string]any, event string) []string {
+	var commands []string
+	hooks, _ := doc["hooks"].(map[string]any)
+	matchers, _ := hooks[event].([]any)
+	for _, m := range matchers {
+		matcher, _ := m.(map[string]any)
+		entries, _ := matcher["hooks"].([]any)
+		for _, e := range entries {
+			entry, _ := e.(map[string]any)
+			if command, ok := entry["command"].(string); ok {
+				commands = append(commands, command)
+			}
+		}
+	}
+	return commands
+}
+
+// RemoveHooks deletes the hooks for event whose command satisfies match,
+// dropping matchers and events left without hooks. It reports whether doc
+// changed.
+func RemoveHooks(doc map[string]any, event string, match func(command string) bool) bool {
+	hooks, _ := doc["hooks"].(map[string]any)
+	matchers, _ := hooks[event].([]any)
+
+	changed := false
+	var keptMatchers []any
+	for _, m := range matchers {
+		matcher, ok := m.(map[string]any)
+		if !ok {
+			keptMatchers = append(keptMatchers, m)
+			continue
+		}
+		entries, _ := matcher["hooks"].([]any)
+		var kept []any
+		for _, e := range entries {
+			entry, _ := e.(map[string]any)
+			if command, ok := entry["command"].(string); ok && match(command) {
+				changed = true
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if len(kept) == 0 && len(entries) > 0 {
+			continue
+		}
+		matcher["hooks"] = kept
+		keptMatchers = append(keptMatchers, matcher)
+	}
+	if !changed {
+		return false
+	}
+
+	if len(keptMatchers) == 0 {
+		delete(hooks, event)
+	} else {
+		hooks[event] = keptMatchers
+	}
+	if len(hooks) == 0 {
+		delete(doc, "hooks")
+	}
+	return true
+}
+
+// LoadSettings reads a Claude settings file as a generic document so that
+// keys sweatshop does not know about survive a rewrite. A missing file
+// yields an empty document; an unparseable one is an error rather than
+// something to silently overwrite.
+func LoadSettings(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]any), nil
+		}
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	return doc, nil
+}
+
+// SaveSettings writes doc to path atomically.
+func SaveSettings(path string, doc map[string]any) error {
+	return saveJSON(path, doc)
+}
+
+// InstallHooks adds a hook per event in commands to the Claude settings file
+// at settingsPath, preserving all other keys. Hooks that are already present
+// are not duplicated.
+func InstallHooks(settingsPath string, commands map[string]string) error {
+	doc, err := LoadSettings(settingsPath)
+	if err != nil {
+		return err
+	}
+
+	events := make([]string, 0, len(commands))
+	for event := range commands {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	changed := false
+	for _, event := range events {
+		if AddHook(doc, event, commands[event]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return SaveSettings(settingsPath, doc)
+}
@@ -0,0 +1,118 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddHookIdempotent(t *testing.T) {
+	doc := map[string]any{}
+
+	if !AddHook(doc, "Stop", "sweatshop hook Stop") {
+		t.Fatal("expected first AddHook to change doc")
+	}
+	if AddHook(doc, "Stop", "sweatshop hook Stop") {
+		t.Error("expected second AddHook to be a no-op")
+	}
+	if !HasHook(doc, "Stop", "sweatshop hook Stop") {
+		t.Error("expected hook to be registered")
+	}
+	if HasHook(doc, "SessionStart", "sweatshop hook Stop") {
+		t.Error("expected hook to be registered for Stop only")
+	}
+}
+
+func TestHookCommandQuotesProgram(t *testing.T) {
+	defer func(program string) { HookProgram = program }(HookProgram)
+
+	HookProgram = "/Users/a b/bin/sweatshop"
+	if got, want := HookCommand("Stop"), "'/Users/a b/bin/sweatshop' hook Stop"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInstallHooksPreservesSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".claude", "settings.local.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, path, map[string]any{
+		"permissions": map[string]any{"allow": []any{"Read"}},
+		"hooks": map[string]any{
+			"Stop": []any{
+				map[string]any{"hooks": []any{
+					map[string]any{"type": "command", "command": "notify-send done"},
+				}},
+			},
+		},
+	})
+
+	if err := InstallHooks(path, SessionHooks()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := InstallHooks(path, SessionHooks()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	doc := readJSON(t, path)
+	if _, ok := doc["permissions"]; !ok {
+		t.Error("expected permissions to be preserved")
+	}
+	for _, event := range SessionHookEvents {
+		if !HasHook(doc, event, HookCommand(event)) {
+			t.Errorf("expected %s hook to be installed", event)
+		}
+	}
+	if !HasHook(doc, "Stop", "notify-send done") {
+		t.Error("expected existing Stop hook to be preserved")
+	}
+
+	stop := doc["hooks"].(map[string]any)["Stop"].([]any)
+	if len(stop) != 2 {
+		t.Errorf("expected 2 Stop matchers after installing twice, got %d", len(stop))
+	}
+}
+
+func TestRemoveHooks(t *testing.T) {
+	doc := map[string]any{"model": "opus"}
+	AddHook(doc, "Stop", "sweatshop hook Stop")
+	AddHook(doc, "Stop", "notify-send done")
+	AddHook(doc, "SessionStart", "sweatshop hook SessionStart")
+
+	isSweatshop := func(c string) bool { return strings.HasPrefix(c, "sweatshop ") }
+
+	if !RemoveHooks(doc, "Stop", isSweatshop) {
+		t.Fatal("expected RemoveHooks to change doc")
+	}
+	if got := HookCommands(doc, "Stop"); len(got) != 1 || got[0] != "notify-send done" {
+		t.Errorf("expected only the notify-send hook to remain, got %v", got)
+	}
+	if RemoveHooks(doc, "Stop", isSweatshop) {
+		t.Error("expected second RemoveHooks to be a no-op")
+	}
+
+	RemoveHooks(doc, "SessionStart", isSweatshop)
+	hooks := doc["hooks"].(map[string]any)
+	if _, ok := hooks["SessionStart"]; ok {
+		t.Error("expected empty SessionStart event to be dropped")
+	}
+
+	RemoveHooks(doc, "Stop", func(string) bool { return true })
+	if _, ok := doc["hooks"]; ok {
+		t.Error("expected empty hooks key to be dropped")
+	}
+	if doc["model"] != "opus" {
+		t.Error("expected other keys to be preserved")
+	}
+}
+
+func TestLoadSettingsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	os.WriteFile(path, []byte("{not json"), 0o644)
+
+	if _, err := LoadSettings(path); err == nil {
+		t.Error("expected error for unparseable settings")
+	}
+}
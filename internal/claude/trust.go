@@ -2,9 +2,12 @@ package claude
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
 )
 
 // TrustWorkspace ensures absPath is trusted in the Claude Code config file at
@@ -34,26 +37,46 @@ func TrustWorkspace(claudeJSONPath, absPath string) error {
 	projects[absPath] = entry
 	doc["projects"] = projects
 
-	data, err := json.MarshalIndent(doc, "", "  ")
+	return saveJSON(claudeJSONPath, doc)
+}
+
+// UntrustWorkspaces removes the entries of absPaths from the Claude Code
+// config file at claudeJSONPath, for worktrees that no longer exist. The file
+// is left alone when it has none of them.
+func UntrustWorkspaces(claudeJSONPath string, absPaths ...string) error {
+	data, err := os.ReadFile(claudeJSONPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	data = append(data, '\n')
 
-	if err := os.MkdirAll(filepath.Dir(claudeJSONPath), 0o755); err != nil {
-		return err
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", claudeJSONPath, err)
 	}
+	projects, _ := doc["projects"].(map[string]any)
 
-	tmp := fmt.Sprintf("%s.tmp.%d", claudeJSONPath, os.Getpid())
-	if err := os.WriteFile(tmp, data, 0o644); err != nil {
-		os.Remove(tmp)
-		return err
+	changed := false
+	for _, absPath := range absPaths {
+		if _, ok := projects[absPath]; ok {
+			delete(projects, absPath)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
 	}
 
-	if err := os.Rename(tmp, claudeJSONPath); err != nil {
-		os.Remove(tmp)
+	return saveJSON(claudeJSONPath, doc)
+}
+
+// saveJSON writes doc to path atomically via a temp file + rename.
+func saveJSON(path string, doc map[string]any) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return filelock.WriteFile(path, append(data, '\n'), 0o644)
 }
@@ -158,3 +158,26 @@ func writeJSON(t *testing.T, path string, doc map[string]any) {
 		t.Fatalf("writing file: %v", err)
 	}
 }
+
+func TestUntrustWorkspaces(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), ".claude.json")
+
+	for _, path := range []string{"/wt/fix-1", "/wt/fix-2", "/wt/other"} {
+		if err := TrustWorkspace(configPath, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := UntrustWorkspaces(configPath, "/wt/fix-1", "/wt/fix-2", "/wt/missing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	projects, _ := readJSON(t, configPath)["projects"].(map[string]any)
+	if len(projects) != 1 || projects["/wt/other"] == nil {
+		t.Errorf("expected only /wt/other to remain, got %v", projects)
+	}
+
+	if err := UntrustWorkspaces(filepath.Join(t.TempDir(), ".claude.json"), "/wt/fix-1"); err != nil {
+		t.Errorf("expected a missing config to be left alone, got %v", err)
+	}
+}
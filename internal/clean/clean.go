@@ -11,7 +11,11 @@ import (
 	"github.com/charmbracelet/log"
 
 	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/ports"
+	"github.com/amarbel-llc/sweatshop/internal/shop"
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
 	"github.com/amarbel-llc/sweatshop/internal/tap"
+	"github.com/amarbel-llc/sweatshop/internal/transcript"
 	"github.com/amarbel-llc/sweatshop/internal/worktree"
 )
 
@@ -95,15 +99,92 @@ func scanWorktrees(startDir string) []worktreeInfo {
 }
 
 func removeWorktree(wt worktreeInfo) error {
-	if err := git.WorktreeRemove(wt.repoPath, wt.worktreePath); err != nil {
-		return fmt.Errorf("removing worktree %s: %w", wt.branch, err)
+	return Remove(wt.repoPath, wt.worktreePath, wt.branch, false)
+}
+
+// Remove deletes a worktree, its scratch dir and its branch, and releases its
+// ports. Without force, git refuses to remove a dirty worktree or delete an
+// unmerged branch.
+func Remove(repoPath, worktreePath, branch string, force bool) error {
+	removeWorktree, deleteBranch := git.WorktreeRemove, git.BranchDelete
+	if force {
+		removeWorktree, deleteBranch = git.WorktreeRemoveForce, git.BranchDeleteForce
+	}
+
+	if err := archiveTranscripts(repoPath, worktreePath, branch); err != nil {
+		return fmt.Errorf("archiving transcripts for %s: %w", branch, err)
+	}
+
+	if err := removeWorktree(repoPath, worktreePath); err != nil {
+		return fmt.Errorf("removing worktree %s: %w", branch, err)
+	}
+	// git removes the ignored scratch dir along with the worktree; this only
+	// catches what it leaves behind, and never runs for a worktree it kept.
+	if err := os.RemoveAll(filepath.Join(worktreePath, sweatfile.ScratchDirName)); err != nil {
+		return fmt.Errorf("removing scratch dir for %s: %w", branch, err)
+	}
+	if err := ports.Release(worktreePath); err != nil {
+		return fmt.Errorf("releasing ports for %s: %w", branch, err)
 	}
-	if err := git.BranchDelete(wt.repoPath, wt.branch); err != nil {
-		return fmt.Errorf("deleting branch %s: %w", wt.branch, err)
+	if err := deleteBranch(repoPath, branch); err != nil {
+		return fmt.Errorf("deleting branch %s: %w", branch, err)
 	}
 	return nil
 }
 
+// archiveTranscripts moves the worktree's session transcripts out of its git
+// admin dir, which git deletes along with the worktree, when the sweatfile
+// asks to keep them.
+func archiveTranscripts(repoPath, worktreePath, branch string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	result, err := sweatfile.LoadHierarchy(home, repoPath)
+	if err != nil {
+		return err
+	}
+	if !result.Merged.KeepTranscripts() {
+		return nil
+	}
+
+	gitDir, err := git.GitDir(worktreePath)
+	if err != nil {
+		return err
+	}
+	paths := transcript.List(filepath.Join(gitDir, worktree.StateDirName, transcript.DirName))
+	if len(paths) == 0 {
+		return nil
+	}
+
+	dest := shop.ArchivedTranscriptsDir(repoPath, branch)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := moveFile(p, filepath.Join(dest, filepath.Base(p))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst, copying when they are on different devices.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
 func discardFile(wtPath string, fc FileChange) error {
 	if fc.Code == "??" {
 		return os.Remove(filepath.Join(wtPath, fc.Path))
@@ -1,7 +1,12 @@
 package clean
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
 )
 
 func TestParsePorcelainEmpty(t *testing.T) {
@@ -129,3 +134,30 @@ func TestParsePorcelainStagedDeleted(t *testing.T) {
 		t.Errorf("expected description 'deleted', got %q", changes[0].Description())
 	}
 }
+
+func TestRemoveKeepsScratchOfDirtyWorktree(t *testing.T) {
+	t.Setenv("SWEATSHOP_STATE_DIR", t.TempDir())
+	repo := t.TempDir()
+	wt := filepath.Join(repo, ".worktrees", "feature")
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"worktree", "add", "-q", "-b", "feature", wt},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	scratchFile := filepath.Join(wt, sweatfile.ScratchDirName, "notes.txt")
+	os.MkdirAll(filepath.Dir(scratchFile), 0o755)
+	os.WriteFile(scratchFile, []byte("keep"), 0o644)
+	os.WriteFile(filepath.Join(wt, "untracked.txt"), []byte("dirty"), 0o644)
+
+	if err := Remove(repo, wt, "feature", false); err == nil {
+		t.Fatal("expected removing a dirty worktree to fail")
+	}
+	if _, err := os.Stat(scratchFile); err != nil {
+		t.Errorf("expected the scratch dir to survive a failed removal: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package devenv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/flake"
+	"github.com/amarbel-llc/sweatshop/internal/shellquote"
+)
+
+const (
+	// Auto selects the first provider whose Detect reports a match.
+	Auto = "auto"
+	// Off disables dev environment wrapping entirely.
+	Off = "off"
+)
+
+// Values of the sweatfile direnv setting. Off (or empty) leaves .envrc alone.
+const (
+	// DirenvAllow runs direnv allow when a worktree is created, so the
+	// user's shell hook loads the .envrc without prompting.
+	DirenvAllow = "allow"
+	// DirenvExec additionally starts sessions through direnv exec, in
+	// place of whichever provider devenv would select.
+	DirenvExec = "exec"
+)
+
+// Provider detects a development environment definition in a directory and
+// wraps commands so that they run inside that environment.
+type Provider interface {
+	// Name is the identifier used by the sweatfile devenv setting.
+	Name() string
+	// Detect reports whether dir contains this provider's environment file.
+	Detect(dir string) bool
+	// Wrap returns command wrapped to run inside the environment for dir.
+	Wrap(dir string, command []string) []string
+	// PrebuildCommand returns a command that materializes the environment
+	// for dir so that later invocations of Wrap start quickly.
+	PrebuildCommand(dir string) []string
+}
+
+// Providers lists the built-in providers in auto-detection priority order.
+// Direnv is listed so that it can be chosen by name, but is not
+// auto-detected; see Detect.
+var Providers = []Provider{
+	Flake{},
+	Devbox{},
+	Mise{},
+	ShellNix{},
+	Direnv{},
+}
+
+// Lookup returns the built-in provider with the given name.
+func Lookup(name string) (Provider, bool) {
+	for _, p := range Providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Detect returns the first provider that detects an environment in dir, or
+// nil when none does. Direnv is skipped: direnv exec refuses an .envrc that
+// has not been allowed, which new worktrees never are by default, so it only
+// wraps sessions when the sweatfile asks for it.
+func Detect(dir string) Provider {
+	for _, p := range Providers {
+		if _, ok := p.(Direnv); ok {
+			continue
+		}
+		if p.Detect(dir) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Select resolves the sweatfile devenv setting for dir. An empty setting or
+// "auto" detects a provider, "off" disables wrapping, and any other value
+// forces the named provider. A nil provider means commands run unwrapped.
+func Select(dir, setting string) (Provider, error) {
+	switch setting {
+	case "", Auto:
+		return Detect(dir), nil
+	case Off:
+		return nil, nil
+	}
+
+	p, ok := Lookup(setting)
+	if !ok {
+		return nil, fmt.Errorf("unknown devenv %q (want %s)", setting, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// CheckDirenvMode validates the sweatfile direnv setting.
+func CheckDirenvMode(mode string) error {
+	switch mode {
+	case "", Off, DirenvAllow, DirenvExec:
+		return nil
+	}
+	return fmt.Errorf("unknown direnv mode %q (want %s, %s, %s)", mode, DirenvAllow, DirenvExec, Off)
+}
+
+// Resolve is Select taking the direnv setting into account. With
+// DirenvExec and an .envrc in dir, direnv is used instead of the devenv
+// provider: an .envrc commonly loads that same environment itself (e.g.
+// "use flake"), so wrapping in both would load it twice. An explicit Off
+// never wraps sessions in direnv, even when devenv names it.
+func Resolve(dir, setting, direnvMode string) (Provider, error) {
+	if err := CheckDirenvMode(direnvMode); err != nil {
+		return nil, err
+	}
+	if direnvMode == DirenvExec && (Direnv{}).Detect(dir) {
+		return Direnv{}, nil
+	}
+
+	p, err := Select(dir, setting)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.(Direnv); ok && direnvMode == Off {
+		return nil, fmt.Errorf("devenv %q conflicts with direnv %q", setting, Off)
+	}
+	return p, nil
+}
+
+// AllowDirenv marks dir's .envrc as trusted. It does nothing when dir has
+// no .envrc.
+func AllowDirenv(dir string) error {
+	if !(Direnv{}).Detect(dir) {
+		return nil
+	}
+	cmd := exec.Command("direnv", "allow", dir)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("direnv allow: %w", err)
+	}
+	return nil
+}
+
+// Names returns the accepted values for the sweatfile devenv setting.
+func Names() []string {
+	names := []string{Auto, Off}
+	for _, p := range Providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// Prebuild runs the provider's prebuild command in dir, streaming its output.
+func Prebuild(p Provider, dir string) error {
+	command := p.PrebuildCommand(dir)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("prebuilding %s environment: %w", p.Name(), err)
+	}
+	return nil
+}
+
+// Flake runs commands in the default devShell of a flake.nix.
+type Flake struct{}
+
+func (Flake) Name() string { return "flake" }
+
+func (Flake) Detect(dir string) bool { return flake.HasDevShell(dir) }
+
+func (Flake) Wrap(dir string, command []string) []string {
+	return append([]string{"nix", "develop", "--command"}, command...)
+}
+
+func (f Flake) PrebuildCommand(dir string) []string {
+	return f.Wrap(dir, []string{"true"})
+}
+
+// ShellNix runs commands in a legacy nix-shell defined by shell.nix.
+type ShellNix struct{}
+
+func (ShellNix) Name() string { return "shell.nix" }
+
+func (ShellNix) Detect(dir string) bool { return fileExists(filepath.Join(dir, "shell.nix")) }
+
+func (ShellNix) Wrap(dir string, command []string) []string {
+	return []string{"nix-shell", "--run", shellquote.Join(command)}
+}
+
+func (s ShellNix) PrebuildCommand(dir string) []string {
+	return s.Wrap(dir, []string{"true"})
+}
+
+// Direnv runs commands with the environment exported by an .envrc.
+type Direnv struct{}
+
+func (Direnv) Name() string { return "direnv" }
+
+func (Direnv) Detect(dir string) bool { return fileExists(filepath.Join(dir, ".envrc")) }
+
+func (Direnv) Wrap(dir string, command []string) []string {
+	return append([]string{"direnv", "exec", dir}, command...)
+}
+
+func (d Direnv) PrebuildCommand(dir string) []string {
+	return d.Wrap(dir, []string{"true"})
+}
+
+// Devbox runs commands in a devbox.json shell.
+type Devbox struct{}
+
+func (Devbox) Name() string { return "devbox" }
+
+func (Devbox) Detect(dir string) bool { return fileExists(filepath.Join(dir, "devbox.json")) }
+
+func (Devbox) Wrap(dir string, command []string) []string {
+	return append([]string{"devbox", "run", "--"}, command...)
+}
+
+func (Devbox) PrebuildCommand(dir string) []string {
+	return []string{"devbox", "install"}
+}
+
+// Mise runs commands with the tools pinned in mise.toml.
+type Mise struct{}
+
+func (Mise) Name() string { return "mise" }
+
+func (Mise) Detect(dir string) bool {
+	return fileExists(filepath.Join(dir, "mise.toml")) || fileExists(filepath.Join(dir, ".mise.toml"))
+}
+
+func (Mise) Wrap(dir string, command []string) []string {
+	return append([]string{"mise", "exec", "--"}, command...)
+}
+
+func (Mise) PrebuildCommand(dir string) []string {
+	return []string{"mise", "install"}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,171 @@
+package devenv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectPriority(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, ".envrc"))
+	touch(t, filepath.Join(dir, "flake.nix"))
+
+	p := Detect(dir)
+	if p == nil || p.Name() != "flake" {
+		t.Fatalf("expected flake to win over direnv, got %v", p)
+	}
+}
+
+func TestDetectEachProvider(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"flake.nix", "flake"},
+		{"shell.nix", "shell.nix"},
+		{"devbox.json", "devbox"},
+		{"mise.toml", "mise"},
+		{".mise.toml", "mise"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := t.TempDir()
+			touch(t, filepath.Join(dir, tt.file))
+
+			p := Detect(dir)
+			if p == nil {
+				t.Fatalf("expected %s to be detected", tt.want)
+			}
+			if p.Name() != tt.want {
+				t.Errorf("Detect() = %q, want %q", p.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectNone(t *testing.T) {
+	if p := Detect(t.TempDir()); p != nil {
+		t.Errorf("expected no provider, got %q", p.Name())
+	}
+}
+
+func TestDetectSkipsDirenv(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, ".envrc"))
+
+	if p := Detect(dir); p != nil {
+		t.Errorf("expected an .envrc alone not to be detected, got %q", p.Name())
+	}
+
+	p, err := Select(dir, "direnv")
+	if err != nil || p == nil || p.Name() != "direnv" {
+		t.Errorf("Select(direnv) = %v, %v; want forced direnv", p, err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "flake.nix"))
+
+	p, err := Select(dir, "")
+	if err != nil || p == nil || p.Name() != "flake" {
+		t.Errorf("Select(\"\") = %v, %v; want flake", p, err)
+	}
+
+	p, err = Select(dir, Off)
+	if err != nil || p != nil {
+		t.Errorf("Select(off) = %v, %v; want nil", p, err)
+	}
+
+	p, err = Select(dir, "mise")
+	if err != nil || p == nil || p.Name() != "mise" {
+		t.Errorf("Select(mise) = %v, %v; want forced mise", p, err)
+	}
+
+	if _, err := Select(dir, "conda"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestResolveDirenvExec(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, ".envrc"))
+	touch(t, filepath.Join(dir, "flake.nix"))
+
+	p, err := Resolve(dir, "", DirenvExec)
+	if err != nil || p == nil || p.Name() != "direnv" {
+		t.Errorf("Resolve(exec) = %v, %v; want direnv to replace flake", p, err)
+	}
+
+	p, err = Resolve(dir, "", DirenvAllow)
+	if err != nil || p == nil || p.Name() != "flake" {
+		t.Errorf("Resolve(allow) = %v, %v; want flake", p, err)
+	}
+
+	noEnvrc := t.TempDir()
+	touch(t, filepath.Join(noEnvrc, "flake.nix"))
+	p, err = Resolve(noEnvrc, "", DirenvExec)
+	if err != nil || p == nil || p.Name() != "flake" {
+		t.Errorf("Resolve(exec) without .envrc = %v, %v; want flake", p, err)
+	}
+
+	if _, err := Resolve(dir, "", "always"); err == nil {
+		t.Error("expected error for unknown direnv mode")
+	}
+}
+
+func TestResolveDirenvOff(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, ".envrc"))
+
+	for _, mode := range []string{"", Off} {
+		p, err := Resolve(dir, "", mode)
+		if err != nil || p != nil {
+			t.Errorf("Resolve(%q) with only an .envrc = %v, %v; want no provider", mode, p, err)
+		}
+	}
+
+	touch(t, filepath.Join(dir, "flake.nix"))
+	p, err := Resolve(dir, "", Off)
+	if err != nil || p == nil || p.Name() != "flake" {
+		t.Errorf("Resolve(off) = %v, %v; want flake", p, err)
+	}
+
+	if _, err := Resolve(dir, "direnv", Off); err == nil {
+		t.Error("expected devenv = direnv to conflict with direnv = off")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cmd := []string{"claude", "--resume"}
+
+	tests := []struct {
+		provider Provider
+		want     []string
+	}{
+		{Flake{}, []string{"nix", "develop", "--command", "claude", "--resume"}},
+		{ShellNix{}, []string{"nix-shell", "--run", "claude --resume"}},
+		{Direnv{}, []string{"direnv", "exec", "/wt", "claude", "--resume"}},
+		{Devbox{}, []string{"devbox", "run", "--", "claude", "--resume"}},
+		{Mise{}, []string{"mise", "exec", "--", "claude", "--resume"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider.Name(), func(t *testing.T) {
+			got := tt.provider.Wrap("/wt", cmd)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Wrap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
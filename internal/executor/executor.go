@@ -1,6 +1,31 @@
 package executor
 
+import "os"
+
+// SessionEnvVar is exported into every sweatshop session with the session
+// key as its value, so nested invocations can tell they are inside one.
+const SessionEnvVar = "SWEATSHOP_SESSION"
+
+// CurrentSession returns the key of the sweatshop session this process runs
+// in, or "" when it is not inside one.
+func CurrentSession() string {
+	return os.Getenv(SessionEnvVar)
+}
+
 type Executor interface {
 	Attach(dir string, key string, command []string) error
 	Detach() error
 }
+
+// Spawner is implemented by executors that can start a session in the
+// background without attaching the current terminal to it.
+type Spawner interface {
+	Spawn(dir string, key string, command []string) error
+}
+
+// Recorder is implemented by executors that can capture the terminal stream
+// of an attached session. Record returns a copy of the executor that writes
+// an asciicast transcript to path on its next Attach.
+type Recorder interface {
+	Record(path string) Executor
+}
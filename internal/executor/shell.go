@@ -3,9 +3,14 @@ package executor
 import (
 	"os"
 	"os/exec"
+
+	"github.com/amarbel-llc/sweatshop/internal/transcript"
 )
 
-type ShellExecutor struct{}
+type ShellExecutor struct {
+	// Transcript, when set, is where the session is recorded.
+	Transcript string
+}
 
 func (s ShellExecutor) Attach(dir string, key string, command []string) error {
 	if len(command) == 0 {
@@ -14,6 +19,9 @@ func (s ShellExecutor) Attach(dir string, key string, command []string) error {
 
 	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Dir = dir
+	if s.Transcript != "" {
+		return transcript.Record(s.Transcript, cmd)
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -23,3 +31,8 @@ func (s ShellExecutor) Attach(dir string, key string, command []string) error {
 func (s ShellExecutor) Detach() error {
 	return nil
 }
+
+func (s ShellExecutor) Record(path string) Executor {
+	s.Transcript = path
+	return s
+}
@@ -3,15 +3,24 @@ package executor
 import (
 	"os"
 	"os/exec"
+
+	"github.com/amarbel-llc/sweatshop/internal/transcript"
 )
 
-type ZmxExecutor struct{}
+type ZmxExecutor struct {
+	// Transcript, when set, is where the attached client's output is
+	// recorded. Time spent detached is not captured.
+	Transcript string
+}
 
 func (z ZmxExecutor) Attach(dir string, key string, command []string) error {
 	args := []string{"attach", key}
 	args = append(args, command...)
 
 	cmd := exec.Command("zmx", args...)
+	if z.Transcript != "" {
+		return transcript.Record(z.Transcript, cmd)
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -25,3 +34,18 @@ func (z ZmxExecutor) Detach() error {
 	cmd.Stdin = os.Stdin
 	return cmd.Run()
 }
+
+func (z ZmxExecutor) Record(path string) Executor {
+	z.Transcript = path
+	return z
+}
+
+func (z ZmxExecutor) Spawn(dir string, key string, command []string) error {
+	args := []string{"run", key}
+	args = append(args, command...)
+
+	cmd := exec.Command("zmx", args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,31 @@
+// Package filelock serializes read-modify-write cycles on shared state files
+// across sweatshop processes.
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// With runs fn holding an exclusive lock on path+".lock", creating the
+// directory of path if needed, so that concurrent processes updating path do
+// not interleave.
+func With(path string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lock(f); err != nil {
+		return fmt.Errorf("locking %s: %w", path, err)
+	}
+	defer unlock(f)
+
+	return fn()
+}
@@ -0,0 +1,64 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestWithSerializesUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := With(path, func() error {
+				data, _ := os.ReadFile(path)
+				n, _ := strconv.Atoi(string(data))
+				return os.WriteFile(path, []byte(strconv.Itoa(n+1)), 0o644)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "20" {
+		t.Errorf("counter = %s, want 20", data)
+	}
+}
+
+func TestWriteFileReplacesContents(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sub")
+	path := filepath.Join(dir, "state.json")
+
+	for _, want := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("contents = %q, want %q", data, want)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the written file left behind, got %d entries", len(entries))
+	}
+}
@@ -110,11 +110,45 @@ func WorktreeRemove(repoPath, worktreePath string) error {
 	return err
 }
 
+func WorktreeRemoveForce(repoPath, worktreePath string) error {
+	_, err := Run(repoPath, "worktree", "remove", "--force", worktreePath)
+	return err
+}
+
+func WorktreeMove(repoPath, from, to string) error {
+	_, err := Run(repoPath, "worktree", "move", from, to)
+	return err
+}
+
 func BranchDelete(repoPath, branch string) error {
 	_, err := Run(repoPath, "branch", "-d", branch)
 	return err
 }
 
+func BranchDeleteForce(repoPath, branch string) error {
+	_, err := Run(repoPath, "branch", "-D", branch)
+	return err
+}
+
+func BranchRename(repoPath, from, to string) error {
+	_, err := Run(repoPath, "branch", "-m", from, to)
+	return err
+}
+
+func RevParse(repoPath, ref string) (string, error) {
+	return Run(repoPath, "rev-parse", "--verify", ref+"^{commit}")
+}
+
+// DiffShortstat returns git's --shortstat summary of branch against its merge
+// base with base, e.g. "3 files changed, 10 insertions(+), 2 deletions(-)".
+func DiffShortstat(path, base, branch string) string {
+	out, err := Run(path, "diff", "--shortstat", base+"..."+branch)
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
 func DefaultBranch(repoPath string) (string, error) {
 	return BranchCurrent(repoPath)
 }
@@ -153,6 +187,28 @@ func CommonDir(worktreePath string) (string, error) {
 	return out, nil
 }
 
+// Toplevel returns the root of the worktree containing path.
+func Toplevel(path string) (string, error) {
+	out, err := Run(path, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(out), nil
+}
+
+// GitDir returns the absolute git admin directory for path. For a linked
+// worktree this is <repo>/.git/worktrees/<name>, which git clean never touches.
+func GitDir(path string) (string, error) {
+	out, err := Run(path, "rev-parse", "--git-dir")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(path, out)
+	}
+	return filepath.Clean(out), nil
+}
+
 func Pull(repoPath string) (string, error) {
 	return Run(repoPath, "pull")
 }
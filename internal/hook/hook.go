@@ -0,0 +1,229 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/amarbel-llc/sweatshop/internal/executor"
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
+	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// StateFileName holds the agent's conversation state inside the worktree
+// state dir.
+const StateFileName = "agent.json"
+
+// Input is the part of a Claude Code hook payload sweatshop uses.
+type Input struct {
+	SessionID      string `json:"session_id"`
+	TranscriptPath string `json:"transcript_path"`
+	CWD            string `json:"cwd"`
+	HookEventName  string `json:"hook_event_name"`
+	// Message and NotificationType are set on Notification events.
+	Message          string `json:"message"`
+	NotificationType string `json:"notification_type"`
+}
+
+// Activity values, describing what the agent is doing right now.
+const (
+	Working  = "working"  // processing a prompt
+	Waiting  = "waiting"  // blocked on a permission prompt
+	Idle     = "idle"     // waiting for the next prompt
+	Finished = "finished" // the session has ended
+)
+
+// State is the last conversation seen in a worktree.
+type State struct {
+	SessionID      string    `json:"session_id"`
+	TranscriptPath string    `json:"transcript_path,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	LastActive     time.Time `json:"last_active"`
+	Activity       string    `json:"activity,omitempty"`
+	// Message is the last notification, e.g. which permission is needed.
+	Message string `json:"message,omitempty"`
+}
+
+// Handle processes a hook payload for event read from r and updates the
+// state of the worktree the session runs in. Sessions outside a sweatshop
+// worktree and events sweatshop does not track are ignored, so a globally
+// installed hook never gets in the way.
+func Handle(event string, r io.Reader) error {
+	var in Input
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return fmt.Errorf("reading hook input: %w", err)
+	}
+	if in.SessionID == "" {
+		return nil
+	}
+
+	cwd := in.CWD
+	if cwd == "" {
+		var err error
+		if cwd, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	path, ok := statePath(cwd)
+	if !ok {
+		return nil
+	}
+
+	previous, _ := load(path)
+	state, changed := apply(previous, event, in, time.Now())
+	if !changed {
+		return nil
+	}
+	if err := save(path, state); err != nil {
+		return err
+	}
+
+	if state.Activity == Waiting && previous.Activity != Waiting {
+		return notify(cwd, state)
+	}
+	return nil
+}
+
+// apply returns state updated for event, and whether the event is tracked.
+// A session id other than the recorded one starts a new conversation.
+func apply(state State, event string, in Input, now time.Time) (State, bool) {
+	var activity string
+	switch event {
+	case "SessionStart":
+		activity = Idle
+	case "UserPromptSubmit", "PostToolUse":
+		// A tool finishing means any permission prompt was answered.
+		activity = Working
+	case "Stop":
+		activity = Idle
+	case "Notification":
+		activity = notificationActivity(state, in)
+	case "SessionEnd":
+		activity = Finished
+	default:
+		return state, false
+	}
+
+	if state.SessionID != in.SessionID {
+		state = State{SessionID: in.SessionID, StartedAt: now}
+	}
+	state.TranscriptPath = in.TranscriptPath
+	state.LastActive = now
+	state.Activity = activity
+	state.Message = in.Message
+	return state, true
+}
+
+// notificationActivity tells a permission prompt from the reminder Claude
+// sends when it has sat idle. PermissionRequest events are not used, as they
+// also fire for requests the perms check hook approves. Claude versions
+// that do not report the notification type only prompt for permission in
+// the middle of a turn.
+func notificationActivity(state State, in Input) string {
+	switch in.NotificationType {
+	case "permission_prompt":
+		return Waiting
+	case "idle_prompt":
+		return Idle
+	case "":
+		if state.SessionID == in.SessionID && state.Activity == Working {
+			return Waiting
+		}
+		return Idle
+	}
+	return state.Activity
+}
+
+// notify runs the sweatfile notify command, if any, for a session that has
+// started waiting. It runs through sh -c with the session described in
+// SWEATSHOP_* variables, and is not waited for so the hook returns at once.
+func notify(dir string, state State) error {
+	root, err := git.Toplevel(dir)
+	if err != nil {
+		return err
+	}
+	repoPath, err := git.CommonDir(root)
+	if err != nil {
+		return err
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	result, err := sweatfile.LoadHierarchy(home, repoPath)
+	if err != nil {
+		return err
+	}
+	if result.Merged.Notify == "" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", result.Merged.Notify)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(),
+		executor.SessionEnvVar+"="+filepath.Base(repoPath)+"/"+filepath.Base(root),
+		"SWEATSHOP_WORKTREE="+root,
+		"SWEATSHOP_ACTIVITY="+state.Activity,
+		"SWEATSHOP_MESSAGE="+state.Message,
+	)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("running notify command: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// statePath returns the state file for the linked worktree containing dir.
+// The main checkout is not a sweatshop worktree, so it reports false there.
+func statePath(dir string) (string, bool) {
+	gitDir, err := git.GitDir(dir)
+	if err != nil {
+		return "", false
+	}
+	if filepath.Base(filepath.Dir(gitDir)) != "worktrees" {
+		return "", false
+	}
+	return filepath.Join(gitDir, worktree.StateDirName, StateFileName), true
+}
+
+// LoadState returns the last recorded conversation of the worktree at path.
+func LoadState(worktreePath string) (State, bool) {
+	path, ok := statePath(worktreePath)
+	if !ok {
+		return State{}, false
+	}
+	state, err := load(path)
+	if err != nil || state.SessionID == "" {
+		return State{}, false
+	}
+	return state, true
+}
+
+func load(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// save writes state through a temp file, since Stop hooks of concurrent
+// sessions may race with a status reading it.
+func save(path string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return filelock.WriteFile(path, append(data, '\n'), 0o644)
+}
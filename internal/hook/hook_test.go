@@ -0,0 +1,76 @@
+package hook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	later := start.Add(time.Hour)
+
+	state, ok := apply(State{}, "SessionStart", Input{SessionID: "abc", TranscriptPath: "/t/abc.jsonl"}, start)
+	if !ok {
+		t.Fatal("expected SessionStart to be tracked")
+	}
+	if state.SessionID != "abc" || !state.StartedAt.Equal(start) || !state.LastActive.Equal(start) {
+		t.Errorf("unexpected state after start: %+v", state)
+	}
+
+	state, _ = apply(state, "Stop", Input{SessionID: "abc", TranscriptPath: "/t/abc.jsonl"}, later)
+	if !state.StartedAt.Equal(start) || !state.LastActive.Equal(later) {
+		t.Errorf("expected Stop to bump last active only, got %+v", state)
+	}
+
+	state, _ = apply(state, "SessionStart", Input{SessionID: "def"}, later)
+	if state.SessionID != "def" || !state.StartedAt.Equal(later) {
+		t.Errorf("expected a new session id to start over, got %+v", state)
+	}
+
+	if _, ok := apply(state, "PreToolUse", Input{SessionID: "def"}, later); ok {
+		t.Error("expected untracked events to be ignored")
+	}
+}
+
+func TestApplyActivity(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		event            string
+		notificationType string
+		want             string
+	}{
+		{"SessionStart", "", Idle},
+		{"UserPromptSubmit", "", Working},
+		{"PermissionRequest", "", Working},
+		{"Notification", "permission_prompt", Waiting},
+		{"PostToolUse", "", Working},
+		{"Notification", "", Waiting},
+		{"PostToolUse", "", Working},
+		{"Stop", "", Idle},
+		{"Notification", "idle_prompt", Idle},
+		{"Notification", "", Idle},
+		{"SessionEnd", "", Finished},
+	}
+
+	var state State
+	for _, tt := range tests {
+		state, _ = apply(state, tt.event, Input{SessionID: "abc", NotificationType: tt.notificationType}, now)
+		if state.Activity != tt.want {
+			t.Errorf("%s %q: activity = %q, want %q", tt.event, tt.notificationType, state.Activity, tt.want)
+		}
+	}
+}
+
+func TestHandleOutsideWorktree(t *testing.T) {
+	input := `{"session_id": "abc", "cwd": "` + t.TempDir() + `"}`
+	if err := Handle("SessionStart", strings.NewReader(input)); err != nil {
+		t.Errorf("expected sessions outside a worktree to be ignored, got %v", err)
+	}
+}
+
+func TestHandleBadInput(t *testing.T) {
+	if err := Handle("SessionStart", strings.NewReader("not json")); err == nil {
+		t.Error("expected error for malformed input")
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/amarbel-llc/sweatshop/internal/executor"
 	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/ports"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
 )
 
 func Run(exec executor.Executor) error {
@@ -21,6 +23,11 @@ func Run(exec executor.Executor) error {
 		return fmt.Errorf("not in a worktree directory: %s", cwd)
 	}
 
+	worktreePath, err := git.Toplevel(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a worktree directory: %s", cwd)
+	}
+
 	branch, err := git.BranchCurrent(cwd)
 	if err != nil {
 		return fmt.Errorf("could not determine current branch: %w", err)
@@ -37,11 +44,26 @@ func Run(exec executor.Executor) error {
 		return err
 	}
 
-	log.Info("removing worktree", "path", cwd)
-	if err := git.RunPassthrough(repoPath, "worktree", "remove", cwd); err != nil {
+	log.Info("removing worktree", "path", worktreePath)
+	if err := git.RunPassthrough(repoPath, "worktree", "remove", worktreePath); err != nil {
 		return err
 	}
+	if err := ports.Release(worktreePath); err != nil {
+		return fmt.Errorf("releasing ports for %s: %w", branch, err)
+	}
 
+	// Detach only from the worktree's own session. Anywhere else, including
+	// sessions started before SWEATSHOP_SESSION existed, which cannot be
+	// told apart from an outer session, detaching would hit the wrong
+	// client.
+	rp, err := worktree.ResolvePath(repoPath, worktreePath)
+	if err != nil {
+		return err
+	}
+	if current := executor.CurrentSession(); current != rp.SessionKey {
+		log.Info("not detaching, merged worktree is not the current session", "session", current, "worktree", rp.SessionKey)
+		return nil
+	}
 	log.Info("detaching from session")
 	return exec.Detach()
 }
@@ -0,0 +1,101 @@
+package merge
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/amarbel-llc/sweatshop/internal/ports"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+type fakeExecutor struct{ detached *bool }
+
+func (f fakeExecutor) Attach(dir, key string, command []string) error { return nil }
+
+func (f fakeExecutor) Detach() error {
+	*f.detached = true
+	return nil
+}
+
+// mergeableWorktree returns a worktree with one commit to merge, and changes
+// into it for the duration of the test.
+func mergeableWorktree(t *testing.T) (repo, wt string) {
+	t.Helper()
+	t.Setenv("SWEATSHOP_STATE_DIR", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@t")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@t")
+
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt = filepath.Join(repo, worktree.WorktreesDir, "feature")
+
+	for _, args := range [][]string{
+		{"-C", repo, "init", "-q"},
+		{"-C", repo, "commit", "-q", "--allow-empty", "-m", "init"},
+		{"-C", repo, "worktree", "add", "-q", "-b", "feature", wt},
+		{"-C", wt, "commit", "-q", "--allow-empty", "-m", "feature"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(wt); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+	return repo, wt
+}
+
+func TestRunReleasesPorts(t *testing.T) {
+	_, wt := mergeableWorktree(t)
+	if _, err := ports.Allocate(wt, map[string]int{"web": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var detached bool
+	if err := Run(fakeExecutor{&detached}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := ports.Lookup(wt); ok {
+		t.Error("expected the merged worktree's ports to be released")
+	}
+}
+
+func TestRunDetachesOnlyOwnSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		session func(repo string) string
+		want    bool
+	}{
+		{"own session", func(repo string) string { return filepath.Base(repo) + "/feature" }, true},
+		{"other session", func(string) string { return "other/main" }, false},
+		{"unmarked session", func(string) string { return "" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := mergeableWorktree(t)
+			t.Setenv("SWEATSHOP_SESSION", tt.session(repo))
+
+			var detached bool
+			if err := Run(fakeExecutor{&detached}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if detached != tt.want {
+				t.Errorf("detached = %v, want %v", detached, tt.want)
+			}
+		})
+	}
+}
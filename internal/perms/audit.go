@@ -0,0 +1,224 @@
+package perms
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// DecisionNone is the audit decision for invocations no rule matched, which
+// fall through to Claude Code's own prompt.
+const DecisionNone = "none"
+
+// The audit log rotates to <path>.1 once it reaches MaxAuditSize, keeping
+// auditBackups rotated files.
+const (
+	MaxAuditSize = 10 << 20
+	auditBackups = 3
+)
+
+// AuditRecord is one perms check decision.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	CWD        string    `json:"cwd"`
+	Repo       string    `json:"repo,omitempty"`
+	Remote     string    `json:"remote,omitempty"`
+	Tool       string    `json:"tool"`
+	Permission string    `json:"permission"`
+	Rule       string    `json:"rule,omitempty"`
+	Tier       string    `json:"tier,omitempty"`
+	// Decision is ListAllow, ListAsk, ListDeny or DecisionNone.
+	Decision string `json:"decision"`
+}
+
+// AuditPath is where perms check records its decisions.
+func AuditPath() string {
+	return filepath.Join(worktree.GlobalStateDir(), "perms-audit.jsonl")
+}
+
+// AppendAudit appends rec to the JSONL audit log at path, rotating the log
+// first when it has grown past MaxAuditSize. Hooks of concurrent sessions
+// append under an exclusive lock so that rotation never loses records.
+func AppendAudit(path string, rec AuditRecord) error {
+	return filelock.With(path, func() error {
+		return appendAudit(path, rec)
+	})
+}
+
+func appendAudit(path string, rec AuditRecord) error {
+	if info, err := os.Stat(path); err == nil && info.Size() >= MaxAuditSize {
+		if err := rotateAudit(path); err != nil {
+			return fmt.Errorf("rotating %s: %w", path, err)
+		}
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func rotateAudit(path string) error {
+	for i := auditBackups - 1; i >= 1; i-- {
+		from := path + "." + strconv.Itoa(i)
+		if err := os.Rename(from, path+"."+strconv.Itoa(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// ReadAudit returns the records of the audit log at path and its rotated
+// files, oldest first. Lines that do not parse are skipped.
+func ReadAudit(path string) ([]AuditRecord, error) {
+	var records []AuditRecord
+
+	for i := auditBackups; i >= 0; i-- {
+		file := path
+		if i > 0 {
+			file = path + "." + strconv.Itoa(i)
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadBytes('\n')
+			var rec AuditRecord
+			if len(line) > 0 && json.Unmarshal(line, &rec) == nil {
+				records = append(records, rec)
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("reading %s: %w", file, err)
+			}
+		}
+		f.Close()
+	}
+
+	return records, nil
+}
+
+// AuditFilter selects audit records. Empty fields match everything.
+type AuditFilter struct {
+	// Repo matches a record's repo name or remote.
+	Repo     string
+	Tool     string
+	Decision string
+	Since    time.Time
+}
+
+func (f AuditFilter) Match(rec AuditRecord) bool {
+	if f.Repo != "" && f.Repo != rec.Repo && f.Repo != rec.Remote {
+		return false
+	}
+	if f.Tool != "" && !matchToolName(f.Tool, rec.Tool) {
+		return false
+	}
+	if f.Decision != "" && f.Decision != rec.Decision {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// FilterAudit returns the records matching f.
+func FilterAudit(records []AuditRecord, f AuditFilter) []AuditRecord {
+	var matched []AuditRecord
+	for _, rec := range records {
+		if f.Match(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	return matched
+}
+
+// Count is a value and how many records had it.
+type Count struct {
+	Value string
+	N     int
+}
+
+// CountBy tallies records by key, most frequent first.
+func CountBy(records []AuditRecord, key func(AuditRecord) string) []Count {
+	tally := map[string]int{}
+	for _, rec := range records {
+		tally[key(rec)]++
+	}
+
+	counts := make([]Count, 0, len(tally))
+	for value, n := range tally {
+		counts = append(counts, Count{Value: value, N: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].N != counts[j].N {
+			return counts[i].N > counts[j].N
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	return counts
+}
+
+// ParseSince parses a --since value: a duration before now such as 90m,
+// 24h or 7d, or a date or time such as 2026-01-31 or 2026-01-31T09:00:00Z.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	t, err := parseTime(s, now, -1)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// parseTime parses a duration such as 90m, 24h or 7d, counted from now in
+// the direction of sign, or a date or time such as 2026-01-31 or
+// 2026-01-31T09:00:00Z.
+func parseTime(s string, now time.Time, sign int) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, sign*n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(time.Duration(sign) * d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("want a duration like 24h or 7d, or a date like 2006-01-02")
+}
@@ -0,0 +1,136 @@
+package perms
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckWritesAuditRecords(t *testing.T) {
+	tiersDir := t.TempDir()
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	data, _ := json.MarshalIndent(Tier{Allow: []string{"Bash(git *)"}}, "", "  ")
+	os.WriteFile(filepath.Join(tiersDir, "global.json"), data, 0o644)
+
+	for _, command := range []string{"git status", "rm -rf /"} {
+		inputJSON, _ := json.Marshal(map[string]any{
+			"tool_name":  "Bash",
+			"tool_input": map[string]any{"command": command},
+			"cwd":        "/home/user/eng/worktrees/myrepo/feature",
+		})
+
+		var out bytes.Buffer
+		opts := CheckOptions{TiersDir: tiersDir, AuditLog: auditPath}
+		if err := RunCheckWith(bytes.NewReader(inputJSON), &out, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	records, err := ReadAudit(auditPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	allowed := records[0]
+	if allowed.Decision != ListAllow || allowed.Rule != "Bash(git *)" || allowed.Tier != "global" {
+		t.Errorf("unexpected allow record %+v", allowed)
+	}
+	if allowed.Repo != "myrepo" || allowed.Tool != "Bash" || allowed.Permission != "Bash(git status)" {
+		t.Errorf("unexpected allow record %+v", allowed)
+	}
+	if records[1].Decision != DecisionNone || records[1].Rule != "" {
+		t.Errorf("expected fall-through record, got %+v", records[1])
+	}
+}
+
+func TestAuditRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	os.WriteFile(path, []byte(strings.Repeat("x", MaxAuditSize)+"\n"), 0o644)
+	os.WriteFile(path+".1", []byte(`{"tool":"Read","decision":"allow"}`+"\n"), 0o644)
+
+	if err := AppendAudit(path, AuditRecord{Tool: "Bash", Decision: DecisionNone}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() > 1024 {
+		t.Errorf("expected a fresh log after rotation, got %v, %v", info, err)
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Errorf("expected previous backup to shift to .2: %v", err)
+	}
+
+	records, err := ReadAudit(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[0].Tool != "Read" || records[1].Tool != "Bash" {
+		t.Errorf("expected records oldest first across rotated files, got %+v", records)
+	}
+}
+
+func TestAuditFilterAndCounts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []AuditRecord{
+		{Time: now.Add(-48 * time.Hour), Repo: "a", Tool: "Bash", Decision: ListAllow},
+		{Time: now.Add(-time.Hour), Repo: "a", Remote: "github.com/o/a", Tool: "Bash", Decision: DecisionNone},
+		{Time: now.Add(-time.Hour), Repo: "b", Tool: "mcp__github__get_issue", Decision: ListAllow},
+	}
+
+	since, err := ParseSince("1d", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   int
+	}{
+		{"all", AuditFilter{}, 3},
+		{"repo name", AuditFilter{Repo: "a"}, 2},
+		{"repo remote", AuditFilter{Repo: "github.com/o/a"}, 1},
+		{"tool wildcard", AuditFilter{Tool: "mcp__github__*"}, 1},
+		{"decision", AuditFilter{Decision: ListAllow}, 2},
+		{"since", AuditFilter{Since: since}, 2},
+	}
+	for _, tt := range tests {
+		if got := len(FilterAudit(records, tt.filter)); got != tt.want {
+			t.Errorf("%s: expected %d records, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	counts := CountBy(records, func(r AuditRecord) string { return r.Decision })
+	if len(counts) != 2 || counts[0] != (Count{Value: ListAllow, N: 2}) {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"90m":                  now.Add(-90 * time.Minute),
+		"7d":                   now.AddDate(0, 0, -7),
+		"2026-02-14":           time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC),
+		"2026-02-14T09:30:00Z": time.Date(2026, 2, 14, 9, 30, 0, 0, time.UTC),
+	}
+	for input, want := range tests {
+		got, err := ParseSince(input, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseSince("last tuesday", now); err == nil {
+		t.Error("expected error for unparseable --since")
+	}
+}
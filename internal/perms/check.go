@@ -2,68 +2,282 @@ package perms
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
 )
 
-// RunCheck reads a PermissionRequest hook payload from r, checks if the tool
-// invocation matches any curated tier rule, and writes an allow decision to w
-// when matched. When no rule matches, nothing is written to w.
-func RunCheck(r io.Reader, w io.Writer, tiersDir string) error {
-	var input struct {
-		ToolName  string         `json:"tool_name"`
-		ToolInput map[string]any `json:"tool_input"`
-		CWD       string         `json:"cwd"`
+// NamedTier is a tier together with the name reported in decisions: the
+// repo name, or "global".
+type NamedTier struct {
+	Name string
+	Tier
+}
+
+// Decision is the outcome of checking a tool invocation against the tiers.
+type Decision struct {
+	// Behavior is one of ListAllow, ListAsk or ListDeny.
+	Behavior string
+	Rule     string
+	Tier     string
+
+	// Parts are the simple commands of a Bash invocation, each with the
+	// allow rule covering it, if any.
+	Parts []PartMatch
+	// ParseErr is set when a Bash command could not be split into simple
+	// commands, which keeps it from being auto-approved.
+	ParseErr error
+}
+
+// PartMatch is one simple command of a Bash invocation and the allow rule
+// that covers it. Rule is empty when no allow rule does.
+type PartMatch struct {
+	Command string
+	Rule    string
+	Tier    string
+}
+
+// Decide checks a tool invocation against tiers, most specific first, with
+// file rule patterns resolved against vars. A deny rule in any tier wins,
+// then an ask rule in any tier, then the first matching allow rule. It
+// reports false when no rule matches; the returned Decision then still
+// describes how a Bash command was split.
+//
+// A Bash command is split with SplitCommand. Deny and ask rules apply when
+// they match the whole command or any simple command in it, while allowing
+// it takes an allow rule for every simple command, so that Bash(git *) does
+// not approve "git status && rm -rf ~". Deny and ask rules are also matched
+// against each simple command with its quoting removed.
+func Decide(tiers []NamedTier, toolName string, toolInput map[string]any, vars PathVars) (Decision, bool) {
+	if command, ok := stringField(toolInput, "command"); ok && toolName == "Bash" {
+		return decideBash(tiers, command)
 	}
 
-	if err := json.NewDecoder(r).Decode(&input); err != nil {
-		return fmt.Errorf("decoding hook input: %w", err)
+	for _, behavior := range []string{ListDeny, ListAsk, ListAllow} {
+		for _, tier := range tiers {
+			if rule, ok := MatchingRuleIn(*tier.List(behavior), toolName, toolInput, vars); ok {
+				return Decision{Behavior: behavior, Rule: rule, Tier: tier.Name}, true
+			}
+		}
 	}
 
-	repo := repoFromCWD(input.CWD)
+	return Decision{}, false
+}
 
-	globalPath := filepath.Join(tiersDir, "global.json")
-	globalTier, err := LoadTierFile(globalPath)
-	if err != nil {
-		return fmt.Errorf("loading global tier: %w", err)
+func decideBash(tiers []NamedTier, command string) (Decision, bool) {
+	parts, err := SplitCommand(command)
+
+	// Ask and deny rules are written without quoting, so they are also
+	// matched against each part as the program sees it: git push '--force'
+	// must not slip past Bash(git push --force*). A part whose quoting cannot be undone might spell out any of them, so
+	// it is left to the user like a command that cannot be split.
+	candidates := append([]string{command}, parts...)
+	for _, part := range parts {
+		plain, dequoteErr := dequoteCommand(part)
+		if dequoteErr != nil {
+			if err == nil {
+				err = fmt.Errorf("%s: %w", part, dequoteErr)
+			}
+			continue
+		}
+		if plain != part {
+			candidates = append(candidates, plain)
+		}
+	}
+	for _, behavior := range []string{ListDeny, ListAsk} {
+		for _, tier := range tiers {
+			for _, candidate := range candidates {
+				if rule, ok := matchingCommandRule(*tier.List(behavior), candidate); ok {
+					return Decision{Behavior: behavior, Rule: rule, Tier: tier.Name}, true
+				}
+			}
+		}
 	}
 
-	var repoTier Tier
-	if repo != "" {
-		repoPath := filepath.Join(tiersDir, "repos", repo+".json")
-		repoTier, err = LoadTierFile(repoPath)
-		if err != nil {
-			return fmt.Errorf("loading repo tier %s: %w", repo, err)
+	decision := Decision{ParseErr: err}
+	if err != nil || len(parts) == 0 {
+		return decision, false
+	}
+
+	var rules, tierNames []string
+	allowed := true
+	for _, part := range parts {
+		match := PartMatch{Command: part}
+		for _, tier := range tiers {
+			if rule, ok := matchingCommandRule(tier.Allow, part); ok {
+				match.Rule, match.Tier = rule, tier.Name
+				break
+			}
+		}
+		decision.Parts = append(decision.Parts, match)
+
+		if match.Rule == "" {
+			allowed = false
+			continue
 		}
+		rules = appendUnique(rules, match.Rule)
+		tierNames = appendUnique(tierNames, match.Tier)
 	}
 
-	tierName := ""
+	if !allowed {
+		return decision, false
+	}
+
+	decision.Behavior = ListAllow
+	decision.Rule = strings.Join(rules, ", ")
+	decision.Tier = strings.Join(tierNames, ", ")
+	return decision, true
+}
+
+func matchingCommandRule(rules []string, command string) (string, bool) {
+	return MatchingRule(rules, "Bash", map[string]any{"command": command})
+}
 
-	if repo != "" {
-		if _, ok := MatchingRule(repoTier.Allow, input.ToolName, input.ToolInput); ok {
-			tierName = repo
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
 		}
 	}
+	return append(list, s)
+}
+
+// LoadCheckTiers loads the tiers that apply to the named repo tiers, most
+// specific first, followed by the global tier. Expired rules are left out. A
+// corrupt tier file is an error wrapping ErrCorruptTier.
+func LoadCheckTiers(tiersDir string, repos []string) ([]NamedTier, error) {
+	return loadTiers(tiersDir, repos, LoadTierFile)
+}
 
-	if tierName == "" {
-		if _, ok := MatchingRule(globalTier.Allow, input.ToolName, input.ToolInput); ok {
-			tierName = "global"
+// loadTiers is LoadCheckTiers loading each tier file with load.
+func loadTiers(tiersDir string, repos []string, load func(string) (Tier, error)) ([]NamedTier, error) {
+	var tiers []NamedTier
+	now := time.Now()
+
+	for _, repo := range repos {
+		repoTier, err := load(TierPath(tiersDir, repo))
+		if err != nil {
+			return nil, fmt.Errorf("loading repo tier %s: %w", repo, err)
 		}
+		tiers = append(tiers, NamedTier{Name: repo, Tier: repoTier.Active(now)})
 	}
 
-	if tierName == "" {
+	globalTier, err := load(TierPath(tiersDir, "global"))
+	if err != nil {
+		return nil, fmt.Errorf("loading global tier: %w", err)
+	}
+	tiers = append(tiers, NamedTier{Name: "global", Tier: globalTier.Active(now)})
+
+	return tiers, nil
+}
+
+// loadCheckTier loads a tier file for the hook. A corrupt file is backed up,
+// reported and then treated as missing, rather than failing every hook call
+// until someone repairs it. Read-only commands such as perms test use
+// LoadTierFile instead, so they report the corrupt file and leave it alone.
+func loadCheckTier(path string) (Tier, error) {
+	tier, err := LoadTierFile(path)
+	if !errors.Is(err, ErrCorruptTier) {
+		return tier, err
+	}
+
+	backup, backupErr := BackupCorruptTier(path)
+	if backupErr != nil {
+		return Tier{}, fmt.Errorf("%w (backing it up: %v)", err, backupErr)
+	}
+	if backup != "" {
+		log.Warn("moved corrupt permission tier aside", "path", path, "backup", backup, "err", err)
+	}
+	return LoadTierFile(path)
+}
+
+// RunCheck reads a PermissionRequest hook payload from r and checks the tool
+// invocation against the curated tiers. A matching deny rule writes a deny
+// decision with the reason to w, and a matching allow rule an allow
+// decision. A matching ask rule, or no match at all, writes nothing so that
+// Claude Code prompts as usual.
+func RunCheck(r io.Reader, w io.Writer, tiersDir string) error {
+	return RunCheckWith(r, w, CheckOptions{TiersDir: tiersDir})
+}
+
+// CheckOptions configure RunCheckWith.
+type CheckOptions struct {
+	TiersDir string
+	// Debug, when set, receives an explanation of the decision, including
+	// which part of a compound Bash command no allow rule covered.
+	Debug io.Writer
+	// AuditLog, when set, is the JSONL file every decision is appended to,
+	// including invocations no rule matched.
+	AuditLog string
+}
+
+// RunCheckWith is RunCheck with debugging and auditing configured by opts.
+func RunCheckWith(r io.Reader, w io.Writer, opts CheckOptions) error {
+	var input Request
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return fmt.Errorf("decoding hook input: %w", err)
+	}
+
+	ev, err := evaluate(opts.TiersDir, input, nil, loadCheckTier)
+	if err != nil {
+		return err
+	}
+
+	permStr, repos := ev.Permission, ev.Repos
+	decision, ok := ev.Decision, ev.Decided
+	if opts.Debug != nil {
+		writeDebug(opts.Debug, permStr, decision, ok)
+	}
+
+	if opts.AuditLog != "" {
+		rec := AuditRecord{
+			Time:       time.Now(),
+			CWD:        input.CWD,
+			Tool:       input.ToolName,
+			Permission: permStr,
+			Decision:   DecisionNone,
+		}
+		if len(repos) > 0 {
+			rec.Repo = repos[len(repos)-1]
+		}
+		if len(repos) > 1 {
+			rec.Remote = repos[0]
+		}
+		if ok {
+			rec.Rule, rec.Tier, rec.Decision = decision.Rule, decision.Tier, decision.Behavior
+		}
+
+		// A failed audit write must not keep the decision from Claude.
+		if err := AppendAudit(opts.AuditLog, rec); err != nil {
+			log.Warn("could not write permission audit log", "path", opts.AuditLog, "err", err)
+		}
+	}
+
+	if !ok || decision.Behavior == ListAsk {
 		return nil
 	}
 
-	permStr := BuildPermissionString(input.ToolName, input.ToolInput)
-	sysMsg := fmt.Sprintf("[sweatshop] auto-approved: %s (%s tier)", permStr, tierName)
+	var behavior map[string]any
+	var sysMsg string
+	switch decision.Behavior {
+	case ListAllow:
+		behavior = map[string]any{"behavior": "allow"}
+		sysMsg = fmt.Sprintf("[sweatshop] auto-approved: %s (%s tier)", permStr, decision.Tier)
+	case ListDeny:
+		reason := fmt.Sprintf("denied by sweatshop rule %s (%s tier)", decision.Rule, decision.Tier)
+		behavior = map[string]any{"behavior": "deny", "message": reason}
+		sysMsg = fmt.Sprintf("[sweatshop] denied: %s (%s tier)", permStr, decision.Tier)
+	}
 
 	output := map[string]any{
 		"hookSpecificOutput": map[string]any{
 			"hookEventName": "PermissionRequest",
-			"decision":      map[string]any{"behavior": "allow"},
+			"decision":      behavior,
 		},
 		"systemMessage": sysMsg,
 	}
@@ -71,9 +285,29 @@ func RunCheck(r io.Reader, w io.Writer, tiersDir string) error {
 	return json.NewEncoder(w).Encode(output)
 }
 
+func writeDebug(w io.Writer, permStr string, decision Decision, ok bool) {
+	if decision.ParseErr != nil {
+		fmt.Fprintf(w, "[sweatshop] cannot split command, not auto-approving: %v\n", decision.ParseErr)
+	}
+	for _, part := range decision.Parts {
+		if part.Rule == "" {
+			fmt.Fprintf(w, "[sweatshop]   %s: no allow rule matches\n", part.Command)
+		} else {
+			fmt.Fprintf(w, "[sweatshop]   %s: %s (%s tier)\n", part.Command, part.Rule, part.Tier)
+		}
+	}
+
+	if !ok {
+		fmt.Fprintf(w, "[sweatshop] no decision for %s\n", permStr)
+		return
+	}
+	fmt.Fprintf(w, "[sweatshop] %s %s: %s (%s tier)\n", decision.Behavior, permStr, decision.Rule, decision.Tier)
+}
+
 // repoFromCWD extracts the repository name from a working directory path by
 // matching the convention-based patterns: .../worktrees/<repo>/... or
-// .../repos/<repo>/...
+// .../repos/<repo>/... It is the fallback for directories git cannot
+// resolve.
 func repoFromCWD(cwd string) string {
 	parts := strings.Split(filepath.ToSlash(cwd), "/")
 
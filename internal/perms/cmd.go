@@ -5,9 +5,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
 	"github.com/spf13/cobra"
 
 	"github.com/amarbel-llc/sweatshop/internal/worktree"
@@ -23,19 +27,40 @@ func NewPermsCmd() *cobra.Command {
 	cmd.AddCommand(newReviewCmd())
 	cmd.AddCommand(newListCmd())
 	cmd.AddCommand(newEditCmd())
+	cmd.AddCommand(newAddCmd())
+	cmd.AddCommand(newRemoveCmd())
+	cmd.AddCommand(newMoveCmd())
+	cmd.AddCommand(newInstallCmd())
+	cmd.AddCommand(newUninstallCmd())
+	cmd.AddCommand(newStatusCmd())
+	cmd.AddCommand(newLogCmd())
+	cmd.AddCommand(newSuggestCmd())
+	cmd.AddCommand(newLintCmd())
+	cmd.AddCommand(newTestCmd())
+	cmd.AddCommand(newExplainCmd())
 
 	return cmd
 }
 
 func newCheckCmd() *cobra.Command {
-	return &cobra.Command{
+	var debug bool
+
+	cmd := &cobra.Command{
 		Use:    "check",
 		Short:  "Handle a PermissionRequest hook",
 		Hidden: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return RunCheck(os.Stdin, os.Stdout, TiersDir())
+			opts := CheckOptions{TiersDir: TiersDir(), AuditLog: AuditPath()}
+			if debug || os.Getenv("SWEATSHOP_PERMS_DEBUG") != "" {
+				opts.Debug = os.Stderr
+			}
+			return RunCheckWith(os.Stdin, os.Stdout, opts)
 		},
 	}
+
+	cmd.Flags().BoolVar(&debug, "debug", false, "explain the decision on stderr (also set by SWEATSHOP_PERMS_DEBUG)")
+
+	return cmd
 }
 
 func newReviewCmd() *cobra.Command {
@@ -83,81 +108,88 @@ func newListCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tiersDir := TiersDir()
 
-			globalPath := filepath.Join(tiersDir, "global.json")
-			globalTier, err := LoadTierFile(globalPath)
+			globalTier, err := LoadTierFile(TierPath(tiersDir, "global"))
 			if err != nil {
 				return fmt.Errorf("loading global tier: %w", err)
 			}
 
-			fmt.Println("Global tier:")
-			if len(globalTier.Allow) == 0 {
-				fmt.Println("  (empty)")
-			} else {
-				for _, rule := range globalTier.Allow {
-					fmt.Printf("  %s\n", rule)
-				}
-			}
+			printTier("Global tier", globalTier)
 
 			if repo != "" {
-				repoPath := filepath.Join(tiersDir, "repos", repo+".json")
-				repoTier, err := LoadTierFile(repoPath)
+				repoTier, err := LoadTierFile(TierPath(tiersDir, repo))
 				if err != nil {
 					return fmt.Errorf("loading repo tier %s: %w", repo, err)
 				}
 
-				fmt.Printf("\nRepo tier (%s):\n", repo)
-				if len(repoTier.Allow) == 0 {
-					fmt.Println("  (empty)")
-				} else {
-					for _, rule := range repoTier.Allow {
-						fmt.Printf("  %s\n", rule)
-					}
-				}
+				fmt.Println()
+				printTier(fmt.Sprintf("Repo tier (%s)", repo), repoTier)
 
 				return nil
 			}
 
-			reposDir := filepath.Join(tiersDir, "repos")
-			entries, err := os.ReadDir(reposDir)
+			repoNames, err := RepoTierFiles(tiersDir)
 			if err != nil {
-				if os.IsNotExist(err) {
-					return nil
-				}
 				return err
 			}
 
-			for _, entry := range entries {
-				if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
-					continue
-				}
-
-				repoName := strings.TrimSuffix(entry.Name(), ".json")
-				repoTier, err := LoadTierFile(filepath.Join(reposDir, entry.Name()))
+			for _, repoName := range repoNames {
+				repoTier, err := LoadTierFile(TierPath(tiersDir, repoName))
 				if err != nil {
 					continue
 				}
 
-				if len(repoTier.Allow) == 0 {
+				if repoTier.Empty() {
 					continue
 				}
 
-				fmt.Printf("\nRepo tier (%s):\n", repoName)
-				for _, rule := range repoTier.Allow {
-					fmt.Printf("  %s\n", rule)
-				}
+				fmt.Println()
+				printTier(fmt.Sprintf("Repo tier (%s)", repoName), repoTier)
 			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&repo, "repo", "", "show rules for a specific repo only")
+	cmd.Flags().StringVar(&repo, "repo", "", "show rules for a specific repo only (a name or remote like github.com/owner/repo)")
 
 	return cmd
 }
 
+// printTier prints the rules of tier under title, each prefixed with the
+// list it belongs to and followed by its reason and expiry, if any.
+func printTier(title string, tier Tier) {
+	now := time.Now()
+
+	fmt.Printf("%s:\n", title)
+	if tier.Empty() {
+		fmt.Println("  (empty)")
+		return
+	}
+
+	for _, list := range Lists {
+		for _, rule := range *tier.List(list) {
+			line := fmt.Sprintf("  %-5s  %s", list, rule)
+
+			info := tier.Info(list, rule)
+			var notes []string
+			if info.Reason != "" {
+				notes = append(notes, info.Reason)
+			}
+			if info.Expired(now) {
+				notes = append(notes, "expired "+info.Expires.Format(time.DateOnly))
+			} else if !info.Expires.IsZero() {
+				notes = append(notes, "expires "+info.Expires.Format(time.DateOnly))
+			}
+			if len(notes) > 0 {
+				line += "  # " + strings.Join(notes, "; ")
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
 func newEditCmd() *cobra.Command {
-	var global bool
+	var global, toTOML bool
 	var repo string
 
 	cmd := &cobra.Command{
@@ -166,17 +198,22 @@ func newEditCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tiersDir := TiersDir()
 
-			var tierPath string
+			name := tierName(repo)
 			if global {
-				tierPath = filepath.Join(tiersDir, "global.json")
-			} else if repo != "" {
-				tierPath = filepath.Join(tiersDir, "repos", repo+".json")
-			} else {
-				tierPath = filepath.Join(tiersDir, "global.json")
+				name = "global"
+			}
+
+			tierPath := TierPath(tiersDir, name)
+			if toTOML {
+				var err error
+				if tierPath, err = ConvertTierToTOML(tiersDir, name); err != nil {
+					return fmt.Errorf("converting tier file: %w", err)
+				}
 			}
 
 			if _, err := os.Stat(tierPath); os.IsNotExist(err) {
-				if err := SaveTierFile(tierPath, Tier{Allow: []string{}}); err != nil {
+				empty := Tier{Allow: []string{}, Ask: []string{}, Deny: []string{}}
+				if err := SaveTierFile(tierPath, empty); err != nil {
 					return fmt.Errorf("creating tier file: %w", err)
 				}
 			}
@@ -196,7 +233,676 @@ func newEditCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&global, "global", false, "edit the global tier file")
-	cmd.Flags().StringVar(&repo, "repo", "", "edit a repo-specific tier file")
+	cmd.Flags().StringVar(&repo, "repo", "", "edit a repo-specific tier file (a name or remote like github.com/owner/repo)")
+	cmd.Flags().BoolVar(&toTOML, "toml", false, "convert the tier to TOML first, which can record a reason, provenance and expiry per rule")
+
+	return cmd
+}
+
+// tierName returns the tier a --repo flag names, "global" when unset.
+func tierName(repo string) string {
+	if repo == "" {
+		return "global"
+	}
+	return repo
+}
+
+func checkListFlag(list string) error {
+	for _, name := range Lists {
+		if list == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --list %q: want allow, ask or deny", list)
+}
+
+func newAddCmd() *cobra.Command {
+	var repo, list, reason, expires string
+
+	cmd := &cobra.Command{
+		Use:   "add <rule>...",
+		Short: "Add rules to a permission tier",
+		Long:  `Add rules to the allow list of the global tier, or of a repo tier with --repo and another list with --list. Rules are checked for syntax first, and rules already in the list are left alone. A TOML tier also records who added each rule and when, along with any --reason and --expires.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkListFlag(list); err != nil {
+				return err
+			}
+			for _, rule := range args {
+				if err := validateRule(rule); err != nil {
+					return fmt.Errorf("invalid rule %q: %w", rule, err)
+				}
+			}
+
+			info := NewRuleInfo(reason, "")
+			if expires != "" {
+				var err error
+				if info.Expires, err = ParseExpires(expires, time.Now()); err != nil {
+					return err
+				}
+			}
+
+			name := tierName(repo)
+			path := TierPath(TiersDir(), name)
+			if filepath.Ext(path) != TierExt && (reason != "" || expires != "") {
+				return fmt.Errorf("the %s tier is JSON, which cannot record --reason or --expires; convert it with perms edit --toml", name)
+			}
+			for _, rule := range args {
+				added, err := AddRuleWithInfo(path, list, rule, info)
+				if err != nil {
+					return fmt.Errorf("adding %s to %s: %w", rule, name, err)
+				}
+				if added {
+					fmt.Printf("added %s to %s %s\n", rule, name, list)
+				} else {
+					fmt.Printf("%s already in %s %s\n", rule, name, list)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repo, "repo", "", "add to a repo tier (a name or remote like github.com/owner/repo) instead of global")
+	cmd.Flags().StringVar(&list, "list", ListAllow, "rule list to add to: allow, ask or deny")
+	cmd.Flags().StringVar(&reason, "reason", "", "why the rules are needed, recorded in TOML tiers")
+	cmd.Flags().StringVar(&expires, "expires", "", "stop applying the rules after a duration like 12h or 30d, or on a date like 2006-01-02")
+
+	return cmd
+}
+
+func newRemoveCmd() *cobra.Command {
+	var repo, list string
+
+	cmd := &cobra.Command{
+		Use:   "remove <rule>...",
+		Short: "Remove rules from a permission tier",
+		Long:  `Remove rules from every list of the global tier, or of a repo tier with --repo. With --list, only that list is changed.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var lists []string
+			if list != "" {
+				if err := checkListFlag(list); err != nil {
+					return err
+				}
+				lists = []string{list}
+			}
+
+			name := tierName(repo)
+			path := TierPath(TiersDir(), name)
+			for _, rule := range args {
+				removed, err := RemoveRule(path, rule, lists...)
+				if err != nil {
+					return fmt.Errorf("removing %s from %s: %w", rule, name, err)
+				}
+				if len(removed) == 0 {
+					fmt.Printf("%s not in %s\n", rule, name)
+					continue
+				}
+				fmt.Printf("removed %s from %s %s\n", rule, name, strings.Join(removed, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&repo, "repo", "", "remove from a repo tier (a name or remote like github.com/owner/repo) instead of global")
+	cmd.Flags().StringVar(&list, "list", "", "only remove from this list: allow, ask or deny")
+
+	return cmd
+}
+
+func newMoveCmd() *cobra.Command {
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "move <rule>...",
+		Short: "Move rules between permission tiers",
+		Long:  `Move rules to the tier named by --to ("global" or a repo tier name), keeping the list each rule is in. The source tier is found by looking for the rule, or given with --from when several tiers hold it.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tiersDir := TiersDir()
+
+			for _, rule := range args {
+				source := from
+				if source == "" {
+					found, err := FindRule(tiersDir, rule)
+					if err != nil {
+						return err
+					}
+					found = RemoveRules(found, []string{to})
+					switch len(found) {
+					case 0:
+						return fmt.Errorf("%s is not in any tier other than %s", rule, to)
+					case 1:
+						source = found[0]
+					default:
+						return fmt.Errorf("%s is in several tiers (%s); pick one with --from", rule, strings.Join(found, ", "))
+					}
+				}
+
+				lists, err := MoveRule(tiersDir, rule, source, to)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("moved %s from %s to %s %s\n", rule, source, to, strings.Join(lists, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", `tier to move to: "global" or a repo tier name`)
+	cmd.Flags().StringVar(&from, "from", "", "tier to move from, when more than one holds the rule")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func newInstallCmd() *cobra.Command {
+	var project string
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Register perms check as Claude Code's PermissionRequest hook",
+		Long:  `Add a PermissionRequest hook running this binary's perms check to ~/.claude/settings.json, or to a project's .claude/settings.json with --project. Other settings and hooks are preserved. Running it again is a no-op, or updates the hook if it points at a different binary.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settingsPath, err := SettingsPath(project)
+			if err != nil {
+				return err
+			}
+			exe, err := CurrentExecutable()
+			if err != nil {
+				return err
+			}
+
+			changed, err := Install(settingsPath, exe)
+			if err != nil {
+				return fmt.Errorf("installing hook in %s: %w", settingsPath, err)
+			}
+			if changed {
+				fmt.Printf("installed %s hook in %s\n", HookEvent, settingsPath)
+			} else {
+				fmt.Printf("%s hook already installed in %s\n", HookEvent, settingsPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&project, "project", "", "install into this project's .claude/settings.json instead")
+
+	return cmd
+}
+
+func newUninstallCmd() *cobra.Command {
+	var project string
+
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the perms check PermissionRequest hook",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settingsPath, err := SettingsPath(project)
+			if err != nil {
+				return err
+			}
+
+			changed, err := Uninstall(settingsPath)
+			if err != nil {
+				return fmt.Errorf("uninstalling hook from %s: %w", settingsPath, err)
+			}
+			if changed {
+				fmt.Printf("removed %s hook from %s\n", HookEvent, settingsPath)
+			} else {
+				fmt.Printf("%s hook not installed in %s\n", HookEvent, settingsPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&project, "project", "", "uninstall from this project's .claude/settings.json instead")
+
+	return cmd
+}
+
+func newStatusCmd() *cobra.Command {
+	var project string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Report whether the PermissionRequest hook is installed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settingsPath, err := SettingsPath(project)
+			if err != nil {
+				return err
+			}
+			exe, err := CurrentExecutable()
+			if err != nil {
+				return err
+			}
+
+			status, err := Status(settingsPath, exe)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case !status.Installed():
+				fmt.Printf("%s hook: not installed in %s\n", HookEvent, settingsPath)
+			case status.Current:
+				fmt.Printf("%s hook: installed in %s\n", HookEvent, settingsPath)
+			default:
+				fmt.Printf("%s hook: installed in %s but not for this binary\n", HookEvent, settingsPath)
+				for _, c := range status.Commands {
+					fmt.Printf("  %s\n", c)
+				}
+				fmt.Printf("current binary is %s; run perms install to update\n", exe)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&project, "project", "", "check this project's .claude/settings.json instead")
+
+	return cmd
+}
+
+func newLogCmd() *cobra.Command {
+	var filter AuditFilter
+	var since string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show recent permission decisions made by perms check",
+		Long:  `Show the decisions perms check recorded in its audit log, including requests no rule matched, followed by counts per decision, tool and repo over every matching record.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch filter.Decision {
+			case "", ListAllow, ListAsk, ListDeny, DecisionNone:
+			default:
+				return fmt.Errorf("invalid --decision %q: want allow, ask, deny or none", filter.Decision)
+			}
+
+			if since != "" {
+				t, err := ParseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				filter.Since = t
+			}
+
+			path := AuditPath()
+			records, err := ReadAudit(path)
+			if err != nil {
+				return err
+			}
+
+			records = FilterAudit(records, filter)
+			if len(records) == 0 {
+				fmt.Printf("no matching decisions in %s\n", path)
+				return nil
+			}
+
+			shown := records
+			if limit > 0 && len(shown) > limit {
+				shown = shown[len(shown)-limit:]
+			}
+			fmt.Println(renderAudit(shown))
+
+			noun := "decisions"
+			if len(records) == 1 {
+				noun = "decision"
+			}
+			fmt.Printf("%d %s", len(records), noun)
+			if len(shown) < len(records) {
+				fmt.Printf(" (last %d shown)", len(shown))
+			}
+			fmt.Println()
+			printCounts("decision", CountBy(records, func(r AuditRecord) string { return r.Decision }))
+			printCounts("tool", CountBy(records, func(r AuditRecord) string { return r.Tool }))
+			printCounts("repo", CountBy(records, func(r AuditRecord) string {
+				if r.Repo == "" {
+					return "-"
+				}
+				return r.Repo
+			}))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.Repo, "repo", "", "only decisions in this repo (a name or remote)")
+	cmd.Flags().StringVar(&filter.Tool, "tool", "", "only decisions for this tool, e.g. Bash or mcp__github__*")
+	cmd.Flags().StringVar(&filter.Decision, "decision", "", "only allow, ask, deny or none decisions")
+	cmd.Flags().StringVar(&since, "since", "", "only decisions after a duration ago (24h, 7d) or a date (2006-01-02)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "show at most this many of the most recent decisions (0 for all)")
+
+	return cmd
+}
+
+func renderAudit(records []AuditRecord) string {
+	const maxPermission = 80
+
+	var rows [][]string
+	for _, r := range records {
+		permission := r.Permission
+		if runes := []rune(permission); len(runes) > maxPermission {
+			permission = string(runes[:maxPermission-1]) + "…"
+		}
+
+		rule := "-"
+		if r.Rule != "" {
+			rule = fmt.Sprintf("%s (%s)", r.Rule, r.Tier)
+		}
+
+		repo := r.Repo
+		if repo == "" {
+			repo = "-"
+		}
+
+		rows = append(rows, []string{r.Time.Local().Format("2006-01-02 15:04:05"), r.Decision, repo, permission, rule})
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("15"))).
+		Headers("Time", "Decision", "Repo", "Permission", "Rule").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			base := lipgloss.NewStyle().PaddingLeft(1).PaddingRight(1)
+
+			if row == table.HeaderRow {
+				return base.Bold(true)
+			}
+
+			if col == 1 {
+				switch rows[row][col] {
+				case ListAllow:
+					return base.Foreground(lipgloss.Color("2"))
+				case ListDeny:
+					return base.Foreground(lipgloss.Color("1"))
+				case ListAsk:
+					return base.Foreground(lipgloss.Color("3"))
+				}
+			}
+
+			return base
+		})
+
+	return t.Render()
+}
+
+func printCounts(label string, counts []Count) {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%s %d", c.Value, c.N)
+	}
+	fmt.Printf("  by %s: %s\n", label, strings.Join(parts, ", "))
+}
+
+func newSuggestCmd() *cobra.Command {
+	var opts SuggestOptions
+	var list bool
+
+	cmd := &cobra.Command{
+		Use:   "suggest [dir]",
+		Short: "Suggest tier promotions from rules approved again and again",
+		Long: `Look through the settings.local.json of every worktree of the repos in dir
+(the current directory by default) and the requests perms check left to
+Claude that were then approved there, and suggest promoting rules approved in many worktrees or repos.
+Similar approvals are generalized, so "go test ./pkg/a" and "go test ./pkg/b"
+suggest Bash(go test:*). Rules seen in several repos are offered for the
+global tier, others for their repo's tier. Accepted rules are removed from the
+settings files they were approved in.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				if dir, err = filepath.Abs(args[0]); err != nil {
+					return err
+				}
+			}
+
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+
+			records, err := ReadAudit(AuditPath())
+			if err != nil {
+				return err
+			}
+
+			obs := SettingsObservations(dir, home)
+			obs = append(obs, AuditObservations(records, obs)...)
+
+			tiersDir := TiersDir()
+			suggestions := Suggest(obs, tiersDir, opts)
+			if len(suggestions) == 0 {
+				fmt.Println("no suggestions")
+				return nil
+			}
+
+			if list {
+				for _, s := range suggestions {
+					printSuggestion(s)
+				}
+				return nil
+			}
+
+			return RunSuggestInteractive(tiersDir, suggestions)
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.MinWorktrees, "min-worktrees", 2, "suggest rules saved in at least this many worktrees")
+	cmd.Flags().IntVar(&opts.MinRequests, "min-requests", 3, "suggest rules left to Claude and approved at least this many times in the audit log")
+	cmd.Flags().BoolVar(&list, "list", false, "print the suggestions without prompting")
+
+	return cmd
+}
+
+// printSuggestion prints a suggested rule with where it was seen and the
+// approvals it generalizes.
+func printSuggestion(s Suggestion) {
+	scope := "global"
+	if len(s.Repos) == 1 {
+		scope = "repo " + s.Repos[0]
+	}
+	fmt.Printf("%s\n  %s: %s\n", s.Rule, scope, suggestionCounts(s))
+	for _, example := range s.Examples {
+		fmt.Printf("    %s\n", example)
+	}
+}
+
+func suggestionCounts(s Suggestion) string {
+	var parts []string
+	if len(s.Repos) > 1 {
+		parts = append(parts, fmt.Sprintf("%d repos", len(s.Repos)))
+	}
+	if s.Worktrees > 0 {
+		parts = append(parts, plural(s.Worktrees, "worktree"))
+	}
+	if s.Requests > 0 {
+		parts = append(parts, plural(s.Requests, "request"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// RunSuggestInteractive asks about each suggestion and routes accepted ones
+// into their tier, dropping the rules they cover from each settings file.
+func RunSuggestInteractive(tiersDir string, suggestions []Suggestion) error {
+	for _, s := range suggestions {
+		title := fmt.Sprintf("Suggested rule: %s (%s)", s.Rule, suggestionCounts(s))
+		if len(s.Examples) > 0 {
+			title += "\ngeneralizes " + strings.Join(s.Examples, ", ")
+		}
+
+		options := []huh.Option[string]{
+			huh.NewOption("Promote to global (all repos)", ReviewPromoteGlobal),
+		}
+		repo := ""
+		if len(s.Repos) == 1 {
+			repo = s.Repos[0]
+			options = append(options, huh.NewOption(fmt.Sprintf("Promote to %s (this repo)", repo), ReviewPromoteRepo))
+		}
+		options = append(options, huh.NewOption("Skip", ReviewKeep))
+
+		var action string
+		selectPrompt := huh.NewSelect[string]().
+			Title(title).
+			Options(options...).
+			Value(&action)
+
+		if err := selectPrompt.Run(); err != nil {
+			return err
+		}
+		if action == ReviewKeep {
+			continue
+		}
+
+		info := NewRuleInfo("perms suggest: "+suggestionCounts(s), "")
+
+		if len(s.Covers) == 0 {
+			if err := RouteDecisions(tiersDir, repo, "", []ReviewDecision{{Rule: s.Rule, Action: action, Info: info}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		settingsPaths := make([]string, 0, len(s.Covers))
+		for settingsPath := range s.Covers {
+			settingsPaths = append(settingsPaths, settingsPath)
+		}
+		sort.Strings(settingsPaths)
+
+		for _, settingsPath := range settingsPaths {
+			decision := ReviewDecision{Rule: s.Rule, Action: action, Covers: s.Covers[settingsPath], Info: info}
+			if err := RouteDecisions(tiersDir, repo, settingsPath, []ReviewDecision{decision}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func newLintCmd() *cobra.Command {
+	var fix bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check permission tiers for duplicate, redundant, shadowed and dangerous rules",
+		Long: `Check the global and repo tiers for rules that appear twice, rules a broader
+rule in the same list already covers, allow and ask rules a deny or ask rule
+always wins over, malformed rules, allow rules that approve every Bash,
+write or WebFetch call, and rules past their expiry. Results are reported as
+TAP. With --fix, duplicate, redundant, shadowed and expired rules are removed
+from the tier files; the others are left for you to edit.`,
+		Args: cobra.NoArgs,
+		// Problems are already reported as TAP.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunLint(TiersDir(), os.Stdout, fix)
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "remove rules that have no effect from the tier files")
+
+	return cmd
+}
+
+func newTestCmd() *cobra.Command {
+	var tiersDir string
+
+	cmd := &cobra.Command{
+		Use:   "test <cases.toml>",
+		Short: "Check permission tiers against expected decisions",
+		Long: `Run the [[case]] tables of a TOML file through the same engine as perms check
+and report TAP, naming the rule behind each decision. A case gives either a
+permission string or a tool and its input, and the decision it expects:
+
+  [[case]]
+  name = "force push needs a prompt"
+  permission = "Bash(git push --force origin main)"
+  repos = ["github.com/owner/proj"]
+  expect = "ask"   # allow, ask, deny or none
+  rule = "Bash(git push:*)"
+
+  [[case]]
+  tool = "Edit"
+  input = { file_path = "src/main.go" }
+  cwd = "."
+  expect = "allow"
+
+A relative cwd is relative to the cases file. Without repos, the repo tiers
+are those of cwd.`,
+		Args: cobra.ExactArgs(1),
+		// Failures are already reported as TAP.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cases, err := LoadTestCases(args[0])
+			if err != nil {
+				return fmt.Errorf("loading %s: %w", args[0], err)
+			}
+			return RunTests(tiersDir, cases, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVar(&tiersDir, "tiers-dir", TiersDir(), "directory holding the global tier and repos/")
+
+	return cmd
+}
+
+func newExplainCmd() *cobra.Command {
+	var tiersDir, cwd string
+	var repos []string
+
+	cmd := &cobra.Command{
+		Use:   "explain <permission>",
+		Short: "Explain how the permission tiers decide a tool call",
+		Long: `Explain the decision perms check makes for a permission string such as
+'Bash(git push)' or 'Read(/etc/hosts)' in a working directory: the tiers
+consulted, how a Bash command splits into simple commands, and the rule that
+decides it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, input, err := ParsePermission(args[0])
+			if err != nil {
+				return err
+			}
+
+			if cwd == "" {
+				if cwd, err = os.Getwd(); err != nil {
+					return err
+				}
+			} else if cwd, err = filepath.Abs(cwd); err != nil {
+				return err
+			}
+
+			if !cmd.Flags().Changed("repo") {
+				repos = nil
+			}
+
+			ev, err := Evaluate(tiersDir, Request{ToolName: tool, ToolInput: input, CWD: cwd}, repos)
+			if err != nil {
+				return err
+			}
+
+			Explain(os.Stdout, ev)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&cwd, "cwd", "", "working directory of the call (default the current directory)")
+	cmd.Flags().StringSliceVar(&repos, "repo", nil, "repo tiers to apply instead of those of --cwd")
+	cmd.Flags().StringVar(&tiersDir, "tiers-dir", TiersDir(), "directory holding the global tier and repos/")
 
 	return cmd
 }
@@ -205,45 +911,54 @@ func RunReviewInteractive(worktreePath, repoName string) error {
 	settingsPath := filepath.Join(worktreePath, ".claude", "settings.local.json")
 	snapshotPath := filepath.Join(worktreePath, ".claude", ".settings-snapshot.json")
 
-	snapshot, err := LoadClaudeSettings(snapshotPath)
+	snapshot, err := LoadClaudePermissions(snapshotPath)
 	if err != nil {
 		return err
 	}
 
-	current, err := LoadClaudeSettings(settingsPath)
+	current, err := LoadClaudePermissions(settingsPath)
 	if err != nil {
 		return err
 	}
 
-	newRules := DiffRules(snapshot, current)
-	if len(newRules) == 0 {
-		return nil
-	}
-
 	tiersDir := TiersDir()
+	info := NewRuleInfo("", worktreePath)
 	var decisions []ReviewDecision
 
-	for _, rule := range newRules {
-		var action string
+	for _, list := range Lists {
+		for _, rule := range DiffRules(*snapshot.List(list), *current.List(list)) {
+			var action string
 
-		selectPrompt := huh.NewSelect[string]().
-			Title(fmt.Sprintf("New permission: %s", rule)).
-			Options(
-				huh.NewOption("Promote to global (all repos)", ReviewPromoteGlobal),
-				huh.NewOption(fmt.Sprintf("Promote to %s (this repo)", repoName), ReviewPromoteRepo),
-				huh.NewOption("Keep for this worktree only", ReviewKeep),
-				huh.NewOption("Discard", ReviewDiscard),
-			).
-			Value(&action)
+			title := fmt.Sprintf("New permission: %s", rule)
+			if list != ListAllow {
+				title = fmt.Sprintf("New %s rule: %s", list, rule)
+			}
 
-		if err := selectPrompt.Run(); err != nil {
-			return err
+			selectPrompt := huh.NewSelect[string]().
+				Title(title).
+				Options(
+					huh.NewOption("Promote to global (all repos)", ReviewPromoteGlobal),
+					huh.NewOption(fmt.Sprintf("Promote to %s (this repo)", repoName), ReviewPromoteRepo),
+					huh.NewOption("Keep for this worktree only", ReviewKeep),
+					huh.NewOption("Discard", ReviewDiscard),
+				).
+				Value(&action)
+
+			if err := selectPrompt.Run(); err != nil {
+				return err
+			}
+
+			decisions = append(decisions, ReviewDecision{
+				Rule:   rule,
+				Action: action,
+				List:   list,
+				Info:   info,
+			})
 		}
+	}
 
-		decisions = append(decisions, ReviewDecision{
-			Rule:   rule,
-			Action: action,
-		})
+	if len(decisions) == 0 {
+		return nil
 	}
 
 	return RouteDecisions(tiersDir, repoName, settingsPath, decisions)
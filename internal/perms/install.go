@@ -0,0 +1,127 @@
+package perms
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/claude"
+	"github.com/amarbel-llc/sweatshop/internal/shellquote"
+)
+
+// HookEvent is the Claude Code hook event that perms check handles.
+const HookEvent = "PermissionRequest"
+
+// checkSuffix identifies a perms check hook regardless of which binary path
+// it was installed with.
+const checkSuffix = " perms check"
+
+// HookCommand returns the hook command that runs perms check with exe,
+// quoted for the shell Claude runs hooks with.
+func HookCommand(exe string) string {
+	return shellquote.Join([]string{exe, "perms", "check"})
+}
+
+func isCheckCommand(command string) bool {
+	return strings.HasSuffix(command, checkSuffix)
+}
+
+// CurrentExecutable returns the resolved path of the running binary, which
+// is what install points the hook at.
+func CurrentExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// SettingsPath returns the Claude settings file to install into: the user's
+// ~/.claude/settings.json, or <project>/.claude/settings.json when project is
+// set.
+func SettingsPath(project string) (string, error) {
+	if project != "" {
+		abs, err := filepath.Abs(project)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(abs, ".claude", "settings.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude", "settings.json"), nil
+}
+
+// Install registers the perms check hook for exe in the settings file at
+// settingsPath, replacing any perms check hook that points at another
+// binary. It reports whether the file changed.
+func Install(settingsPath, exe string) (bool, error) {
+	doc, err := claude.LoadSettings(settingsPath)
+	if err != nil {
+		return false, err
+	}
+
+	command := HookCommand(exe)
+	removed := claude.RemoveHooks(doc, HookEvent, func(c string) bool {
+		return isCheckCommand(c) && c != command
+	})
+	added := claude.AddHook(doc, HookEvent, command)
+	if !removed && !added {
+		return false, nil
+	}
+
+	return true, claude.SaveSettings(settingsPath, doc)
+}
+
+// Uninstall removes every perms check hook from the settings file at
+// settingsPath. It reports whether the file changed.
+func Uninstall(settingsPath string) (bool, error) {
+	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	doc, err := claude.LoadSettings(settingsPath)
+	if err != nil {
+		return false, err
+	}
+
+	if !claude.RemoveHooks(doc, HookEvent, isCheckCommand) {
+		return false, nil
+	}
+	return true, claude.SaveSettings(settingsPath, doc)
+}
+
+// HookStatus describes the perms check hooks found in a settings file.
+type HookStatus struct {
+	// Commands are the installed perms check hook commands.
+	Commands []string
+	// Current is true when one of them runs the current binary.
+	Current bool
+}
+
+func (s HookStatus) Installed() bool {
+	return len(s.Commands) > 0
+}
+
+// Status inspects the settings file at settingsPath for perms check hooks.
+func Status(settingsPath, exe string) (HookStatus, error) {
+	doc, err := claude.LoadSettings(settingsPath)
+	if err != nil {
+		return HookStatus{}, err
+	}
+
+	var status HookStatus
+	for _, c := range claude.HookCommands(doc, HookEvent) {
+		if !isCheckCommand(c) {
+			continue
+		}
+		status.Commands = append(status.Commands, c)
+		if c == HookCommand(exe) {
+			status.Current = true
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,124 @@
+package perms
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amarbel-llc/sweatshop/internal/claude"
+)
+
+func TestInstallIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".claude", "settings.json")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte(`{"model": "opus", "permissions": {"allow": ["Read"]}}`), 0o644)
+
+	changed, err := Install(path, "/usr/bin/sweatshop")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Error("expected first install to change settings")
+	}
+
+	changed, err = Install(path, "/usr/bin/sweatshop")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("expected second install to be a no-op")
+	}
+
+	doc := readSettings(t, path)
+	if doc["model"] != "opus" {
+		t.Error("expected model to be preserved")
+	}
+	if _, ok := doc["permissions"]; !ok {
+		t.Error("expected permissions to be preserved")
+	}
+	got := claude.HookCommands(doc, HookEvent)
+	if len(got) != 1 || got[0] != "/usr/bin/sweatshop perms check" {
+		t.Errorf("expected a single perms check hook, got %v", got)
+	}
+}
+
+func TestHookCommandQuotesExecutable(t *testing.T) {
+	got := HookCommand("/Users/a b/bin/sweatshop")
+	if want := "'/Users/a b/bin/sweatshop' perms check"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !isCheckCommand(got) {
+		t.Error("expected a quoted command to still be recognized as perms check")
+	}
+}
+
+func TestInstallReplacesStaleBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	Install(path, "/old/sweatshop")
+	doc := readSettings(t, path)
+	claude.AddHook(doc, HookEvent, "other-tool approve")
+	claude.SaveSettings(path, doc)
+
+	status, err := Status(path, "/new/sweatshop")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Installed() || status.Current {
+		t.Errorf("expected installed but stale hook, got %+v", status)
+	}
+
+	if _, err := Install(path, "/new/sweatshop"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := claude.HookCommands(readSettings(t, path), HookEvent)
+	if len(got) != 2 || got[0] != "other-tool approve" || got[1] != "/new/sweatshop perms check" {
+		t.Errorf("expected stale hook replaced and other hook kept, got %v", got)
+	}
+
+	status, _ = Status(path, "/new/sweatshop")
+	if !status.Current {
+		t.Error("expected hook to point at the current binary")
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	changed, err := Uninstall(path)
+	if err != nil || changed {
+		t.Errorf("expected uninstall without settings to be a no-op, got %v, %v", changed, err)
+	}
+
+	Install(path, "/usr/bin/sweatshop")
+	changed, err = Uninstall(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Error("expected uninstall to change settings")
+	}
+
+	doc := readSettings(t, path)
+	if _, ok := doc["hooks"]; ok {
+		t.Errorf("expected hooks to be removed, got %v", doc["hooks"])
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected settings file to remain: %v", err)
+	}
+}
+
+func readSettings(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parsing %s: %v", path, err)
+	}
+	return doc
+}
@@ -0,0 +1,531 @@
+package perms
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amarbel-llc/sweatshop/internal/tap"
+)
+
+// Kinds of problems perms lint reports.
+const (
+	// LintDuplicate is a rule that appears earlier in the same list, or in
+	// the same list of the global tier.
+	LintDuplicate = "duplicate"
+	// LintRedundant is a rule a broader rule in the same list already
+	// covers.
+	LintRedundant = "redundant"
+	// LintShadowed is a rule that never takes effect because an ask or deny
+	// rule covering it wins first.
+	LintShadowed = "shadowed"
+	// LintInvalid is a rule parseRule accepts but that does not mean what
+	// it looks like.
+	LintInvalid = "invalid"
+	// LintOverbroad is an allow rule that approves every use of a tool that
+	// can run commands, write files or reach the network.
+	LintOverbroad = "overbroad"
+	// LintExpired is a rule past its expiry, which perms check ignores.
+	LintExpired = "expired"
+)
+
+// LintProblem is a problem with one rule of a tier.
+type LintProblem struct {
+	Tier string
+	List string
+	// Index is the position of Rule in its list.
+	Index int
+	Rule  string
+	Kind  string
+	// By is the rule, in ByTier, that makes Rule a duplicate, redundant or
+	// shadowed.
+	By      string
+	ByTier  string
+	Message string
+}
+
+// Fixable reports whether removing the rule fixes the problem without
+// changing what the tiers decide.
+func (p LintProblem) Fixable() bool {
+	switch p.Kind {
+	case LintDuplicate, LintRedundant, LintShadowed, LintExpired:
+		return true
+	}
+	return false
+}
+
+// overbroadTools are the tools for which an unrestricted allow rule hands
+// over the machine.
+var overbroadTools = map[string]bool{
+	"Bash": true, "Write": true, "Edit": true, "MultiEdit": true,
+	"NotebookEdit": true, "WebFetch": true,
+}
+
+// matchAllPatterns are the patterns that match any argument.
+var matchAllPatterns = map[string]bool{
+	"*": true, ":*": true, "**": true, "/**": true, "//**": true, "domain:*": true,
+}
+
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*\*?$`)
+
+// RunLint checks the global tier and every repo tier under tiersDir,
+// reporting each problem as a failed TAP test point and each clean tier as a
+// passing one. With fix, rules whose removal does not change any decision
+// are removed from the tier files and reported as passing.
+func RunLint(tiersDir string, w io.Writer, fix bool) error {
+	tw := tap.NewWriter(w)
+	failed := 0
+
+	lint := func(name, path string, global *NamedTier) (NamedTier, bool) {
+		nt := NamedTier{Name: name}
+		var problems []LintProblem
+		check := func(tier *Tier) (bool, error) {
+			nt.Tier = *tier
+			problems = LintTier(nt, global)
+			if !fix || !anyFixable(problems) {
+				return false, nil
+			}
+			*tier = FixTier(*tier, problems)
+			nt.Tier = *tier
+			return true, nil
+		}
+
+		var err error
+		if fix {
+			// Lint under the tier's lock, so that the rewrite cannot drop a
+			// rule added in the meantime.
+			err = updateTierFile(path, check)
+		} else {
+			var tier Tier
+			if tier, err = LoadTierFile(path); err == nil {
+				check(&tier)
+			}
+		}
+		if err != nil {
+			failed++
+			tw.NotOk(name, map[string]string{"message": err.Error(), "severity": "fail"})
+			return NamedTier{}, false
+		}
+
+		if len(problems) == 0 {
+			tw.Ok(name)
+			return nt, true
+		}
+
+		for _, p := range problems {
+			desc := fmt.Sprintf("%s %s %s", p.Tier, p.List, p.Rule)
+			if fix && p.Fixable() {
+				tw.Ok(fmt.Sprintf("%s # removed, %s: %s", desc, p.Kind, p.Message))
+				continue
+			}
+
+			failed++
+			severity := "warn"
+			if !p.Fixable() {
+				severity = "fail"
+			}
+			tw.NotOk(desc, map[string]string{
+				"kind":     p.Kind,
+				"message":  p.Message,
+				"severity": severity,
+			})
+		}
+		return nt, true
+	}
+
+	global, ok := lint("global", TierPath(tiersDir, "global"), nil)
+	globalTier := &global
+	if !ok {
+		globalTier = nil
+	}
+
+	repos, err := RepoTierFiles(tiersDir)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		lint(repo, TierPath(tiersDir, repo), globalTier)
+	}
+
+	tw.Plan()
+
+	if failed > 0 {
+		return fmt.Errorf("%d problem(s) in permission tiers", failed)
+	}
+	return nil
+}
+
+// LintTier checks tier, which is global itself when global is nil. Rules in
+// a repo tier are also checked against the global tier, as both apply to
+// sessions in that repo.
+func LintTier(tier NamedTier, global *NamedTier) []LintProblem {
+	now := time.Now()
+	if global != nil {
+		active := NamedTier{Name: global.Name, Tier: global.Active(now)}
+		global = &active
+	}
+
+	var problems []LintProblem
+	removed := map[string]map[int]bool{}
+	for _, list := range Lists {
+		removed[list] = map[int]bool{}
+	}
+
+	report := func(list string, i int, p LintProblem) {
+		p.Tier = tier.Name
+		p.List = list
+		p.Index = i
+		p.Rule = (*tier.List(list))[i]
+		if p.Fixable() {
+			removed[list][i] = true
+		}
+		problems = append(problems, p)
+	}
+
+	// covering returns the first rule of list in t, other than skip, that
+	// covers rule and has not been reported for removal. Overbroad rules are
+	// not counted, so that fixing them does not lose the rules they cover,
+	// and neither are rules that expire, which stop covering anything then.
+	covering := func(t NamedTier, list string, dropped map[int]bool, skip int, rule string) (string, bool) {
+		for j, other := range *t.List(list) {
+			if j == skip || other == rule || dropped[j] || expires(t.Tier, list, other) {
+				continue
+			}
+			if list == ListAllow && isOverbroad(other) {
+				continue
+			}
+			if validateRule(other) == nil && ruleCovers(other, rule) {
+				return other, true
+			}
+		}
+		return "", false
+	}
+
+	valid := map[string][]bool{}
+	for _, list := range Lists {
+		rules := *tier.List(list)
+		valid[list] = make([]bool, len(rules))
+		for i, rule := range rules {
+			if err := validateRule(rule); err != nil {
+				report(list, i, LintProblem{Kind: LintInvalid, Message: err.Error()})
+				continue
+			}
+			if info := tier.Info(list, rule); info.Expired(now) {
+				report(list, i, LintProblem{Kind: LintExpired, Message: "expired " + info.Expires.Format(time.DateOnly)})
+				continue
+			}
+			valid[list][i] = true
+			if list == ListAllow && isOverbroad(rule) {
+				tool, _ := parseRule(rule)
+				report(list, i, LintProblem{Kind: LintOverbroad, Message: "allows every " + tool + " call"})
+			}
+		}
+	}
+
+	for _, list := range Lists {
+		rules := *tier.List(list)
+		seen := map[string]bool{}
+		for i, rule := range rules {
+			if !valid[list][i] {
+				continue
+			}
+			if seen[rule] {
+				report(list, i, LintProblem{Kind: LintDuplicate, By: rule, ByTier: tier.Name,
+					Message: "appears earlier in " + tier.Name})
+				continue
+			}
+			seen[rule] = true
+			if global != nil && contains(*global.List(list), rule) && !expires(global.Tier, list, rule) {
+				report(list, i, LintProblem{Kind: LintDuplicate, By: rule, ByTier: global.Name,
+					Message: "also in " + global.Name})
+			}
+		}
+	}
+
+	for _, list := range Lists {
+		rules := *tier.List(list)
+		for i, rule := range rules {
+			if !valid[list][i] || removed[list][i] {
+				continue
+			}
+			if by, ok := covering(tier, list, removed[list], i, rule); ok {
+				report(list, i, LintProblem{Kind: LintRedundant, By: by, ByTier: tier.Name,
+					Message: fmt.Sprintf("covered by %s in %s", by, tier.Name)})
+				continue
+			}
+			if global == nil {
+				continue
+			}
+			if by, ok := covering(*global, list, nil, -1, rule); ok {
+				report(list, i, LintProblem{Kind: LintRedundant, By: by, ByTier: global.Name,
+					Message: fmt.Sprintf("covered by %s in %s", by, global.Name)})
+			}
+		}
+	}
+
+	// Deny rules win over ask rules, and both over allow rules, whichever
+	// tier they are in.
+	stronger := map[string][]string{
+		ListAllow: {ListDeny, ListAsk},
+		ListAsk:   {ListDeny},
+	}
+	tiers := []NamedTier{tier}
+	if global != nil {
+		tiers = append(tiers, *global)
+	}
+
+	for _, list := range []string{ListAllow, ListAsk} {
+		rules := *tier.List(list)
+	rules:
+		for i, rule := range rules {
+			if !valid[list][i] || removed[list][i] {
+				continue
+			}
+			for _, strongerList := range stronger[list] {
+				for _, t := range tiers {
+					var dropped map[int]bool
+					if t.Name == tier.Name {
+						dropped = removed[strongerList]
+					}
+					if by, ok := shadowingRule(t.Tier, strongerList, dropped, rule); ok {
+						report(list, i, LintProblem{Kind: LintShadowed, By: by, ByTier: t.Name,
+							Message: fmt.Sprintf("%s rule %s in %s wins first", strongerList, by, t.Name)})
+						continue rules
+					}
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// shadowingRule returns the rule of list in tier that covers rule. Rules
+// that expire, and those at an index in dropped, are ignored.
+func shadowingRule(tier Tier, list string, dropped map[int]bool, rule string) (string, bool) {
+	for j, other := range *tier.List(list) {
+		if dropped[j] || expires(tier, list, other) {
+			continue
+		}
+		if validateRule(other) == nil && (other == rule || ruleCovers(other, rule)) {
+			return other, true
+		}
+	}
+	return "", false
+}
+
+// expires reports whether rule of list in tier has an expiry, after which
+// the rules it covers are needed again.
+func expires(tier Tier, list, rule string) bool {
+	return !tier.Info(list, rule).Expires.IsZero()
+}
+
+func anyFixable(problems []LintProblem) bool {
+	for _, p := range problems {
+		if p.Fixable() {
+			return true
+		}
+	}
+	return false
+}
+
+// FixTier returns tier without the rules of the fixable problems reported
+// for it.
+func FixTier(tier Tier, problems []LintProblem) Tier {
+	drop := map[string]map[int]bool{}
+	for _, p := range problems {
+		if !p.Fixable() {
+			continue
+		}
+		if drop[p.List] == nil {
+			drop[p.List] = map[int]bool{}
+		}
+		drop[p.List][p.Index] = true
+	}
+
+	fixed := Tier{info: tier.info}
+	for _, list := range Lists {
+		kept := []string{}
+		for i, rule := range *tier.List(list) {
+			if !drop[list][i] {
+				kept = append(kept, rule)
+			}
+		}
+		*fixed.List(list) = kept
+	}
+	return fixed
+}
+
+// validateRule catches rules parseRule accepts but reads differently from
+// how they look, e.g. "Bash()" which it takes for a bare Bash rule.
+func validateRule(rule string) error {
+	if strings.TrimSpace(rule) == "" {
+		return fmt.Errorf("empty rule")
+	}
+	if strings.TrimSpace(rule) != rule {
+		return fmt.Errorf("leading or trailing whitespace")
+	}
+
+	open := strings.Index(rule, "(")
+	if open < 0 {
+		if strings.Contains(rule, ")") {
+			return fmt.Errorf("unbalanced parentheses")
+		}
+		return validateToolName(rule)
+	}
+
+	if err := validateToolName(rule[:open]); err != nil {
+		return err
+	}
+	if !strings.HasSuffix(rule, ")") {
+		return fmt.Errorf("missing closing parenthesis")
+	}
+
+	tool, pattern := parseRule(rule)
+	if pattern == "" {
+		return fmt.Errorf("empty parentheses match every %s call", tool)
+	}
+	if depth := parenDepth(pattern); depth != 0 {
+		return fmt.Errorf("unbalanced parentheses")
+	}
+	switch domain, isDomain := strings.CutPrefix(pattern, domainPrefix); {
+	case isDomain && tool != "WebFetch":
+		return fmt.Errorf("domain: patterns only apply to WebFetch")
+	case isDomain:
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			return fmt.Errorf("domain %q is not a host name", domain)
+		}
+		if _, err := path.Match(domain, ""); err != nil {
+			return fmt.Errorf("bad domain pattern %q", domain)
+		}
+	case fileTools[tool]:
+		for _, seg := range splitPath(pattern) {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("bad glob %q", seg)
+			}
+		}
+	default:
+		if i := strings.Index(pattern, ":*"); i >= 0 && i != len(pattern)-2 {
+			return fmt.Errorf(":* only works at the end of a pattern")
+		}
+	}
+
+	return nil
+}
+
+func validateToolName(tool string) error {
+	if !toolNamePattern.MatchString(tool) {
+		return fmt.Errorf("bad tool name %q", tool)
+	}
+	return nil
+}
+
+func parenDepth(s string) int {
+	depth := 0
+	for _, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return depth
+			}
+		}
+	}
+	return depth
+}
+
+func isOverbroad(rule string) bool {
+	tool, pattern := parseRule(rule)
+	if tool == "*" || strings.HasSuffix(tool, "*") && !strings.HasPrefix(tool, mcpPrefix) {
+		return true
+	}
+	return overbroadTools[tool] && (pattern == "" || matchAllPatterns[pattern])
+}
+
+// ruleCovers reports whether every call narrow matches is also matched by
+// broad. It errs towards false for globs it cannot compare.
+func ruleCovers(broad, narrow string) bool {
+	broadTool, broadPattern := parseRule(broad)
+	narrowTool, narrowPattern := parseRule(narrow)
+
+	if broadPattern == "" {
+		return matchToolName(broadTool, narrowTool)
+	}
+	if narrowPattern == "" || broadTool != narrowTool {
+		return false
+	}
+
+	switch {
+	case fileTools[broadTool]:
+		return pathCovers(broadPattern, narrowPattern)
+
+	case broadTool == "WebFetch" && strings.HasPrefix(broadPattern, domainPrefix):
+		domain := strings.ToLower(strings.TrimPrefix(broadPattern, domainPrefix))
+		if narrowDomain, ok := strings.CutPrefix(narrowPattern, domainPrefix); ok {
+			matched, _ := path.Match(domain, strings.ToLower(narrowDomain))
+			return matched
+		}
+		return !strings.ContainsAny(narrowPattern, "*") && matchDomain(domain, narrowPattern)
+	}
+
+	return patternCovers(broadPattern, narrowPattern)
+}
+
+// patternCovers compares two matchPattern patterns.
+func patternCovers(broad, narrow string) bool {
+	var narrowPrefix string
+	var narrowWords bool
+	switch {
+	case strings.HasSuffix(narrow, ":*"):
+		narrowPrefix, narrowWords = strings.TrimSuffix(narrow, ":*"), true
+	case strings.HasSuffix(narrow, "*"):
+		narrowPrefix = strings.TrimSuffix(narrow, "*")
+	default:
+		return matchPattern(broad, narrow)
+	}
+
+	switch {
+	case strings.HasSuffix(broad, ":*"):
+		prefix := strings.TrimSuffix(broad, ":*")
+		return (narrowWords && narrowPrefix == prefix) || strings.HasPrefix(narrowPrefix, prefix+" ")
+	case strings.HasSuffix(broad, "*"):
+		return strings.HasPrefix(narrowPrefix, strings.TrimSuffix(broad, "*"))
+	}
+	return false
+}
+
+// pathCovers compares two file rule patterns textually, placeholders
+// included. The narrow one may only use globs in a trailing /**.
+func pathCovers(broad, narrow string) bool {
+	broad, narrow = absolutePattern(broad), absolutePattern(narrow)
+	if strings.HasPrefix(broad, "/") != strings.HasPrefix(narrow, "/") {
+		return false
+	}
+
+	dir := strings.TrimSuffix(narrow, "/**")
+	if strings.ContainsAny(dir, "*?[") {
+		return false
+	}
+
+	broadSegs := splitPath(broad)
+	segs := splitPath(dir)
+	for n := len(segs); n > 0; n-- {
+		if matchSegments(broadSegs, segs[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// absolutePattern rewrites the // prefix of an absolute pattern to /.
+func absolutePattern(pattern string) string {
+	if strings.HasPrefix(pattern, "//") {
+		return pattern[1:]
+	}
+	return pattern
+}
@@ -0,0 +1,229 @@
+package perms
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRule(t *testing.T) {
+	valid := []string{
+		"Read",
+		"Bash(git status)",
+		"Bash(go test:*)",
+		"Bash(echo $(date))",
+		"Edit($WORKTREE/**)",
+		"WebFetch(domain:*.github.com)",
+		"mcp__github__*",
+	}
+	for _, rule := range valid {
+		if err := validateRule(rule); err != nil {
+			t.Errorf("validateRule(%q) = %v, want nil", rule, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		" Bash(ls)",
+		"Bash()",
+		"Bash (git status)",
+		"Bash(git status",
+		"Bash(git status))",
+		"Read)",
+		"Bash(git:* push)",
+		"Bash(domain:example.com)",
+		"WebFetch(domain:https://example.com)",
+		"Read(/src/[a)",
+	}
+	for _, rule := range invalid {
+		if err := validateRule(rule); err == nil {
+			t.Errorf("validateRule(%q) = nil, want an error", rule)
+		}
+	}
+}
+
+func TestRuleCovers(t *testing.T) {
+	tests := []struct {
+		broad, narrow string
+		want          bool
+	}{
+		{"Bash(git *)", "Bash(git status)", true},
+		{"Bash(git *)", "Bash(git push:*)", true},
+		{"Bash(git:*)", "Bash(git push:*)", true},
+		{"Bash(git:*)", "Bash(git *)", true},
+		{"Bash(git *)", "Bash(git:*)", false},
+		{"Bash(git:*)", "Bash(gitk)", false},
+		{"Bash(git status)", "Bash(git status --short)", false},
+		{"Bash", "Bash(rm -rf:*)", true},
+		{"Bash(git *)", "Bash", false},
+		{"mcp__github", "mcp__github__get_issue", true},
+		{"Read(/src/**)", "Read(//src/a/b.go)", true},
+		{"Read(/src/**)", "Read(/src/a/**)", true},
+		{"Read(/src/*.go)", "Read(/src/a.go)", true},
+		{"Read(/src/*.go)", "Read(/src/**)", false},
+		{"Read($WORKTREE/**)", "Read($WORKTREE/docs/a.md)", true},
+		{"Read(/src/**)", "Edit(/src/a.go)", false},
+		{"WebFetch(domain:*.github.com)", "WebFetch(domain:api.github.com)", true},
+		{"WebFetch(domain:github.com)", "WebFetch(https://github.com/x)", true},
+		{"WebFetch(domain:github.com)", "WebFetch(domain:*.github.com)", false},
+	}
+
+	for _, tt := range tests {
+		if got := ruleCovers(tt.broad, tt.narrow); got != tt.want {
+			t.Errorf("ruleCovers(%q, %q) = %v, want %v", tt.broad, tt.narrow, got, tt.want)
+		}
+	}
+}
+
+func lintKinds(problems []LintProblem) map[string]string {
+	kinds := map[string]string{}
+	for _, p := range problems {
+		kinds[p.List+" "+p.Rule] = p.Kind
+	}
+	return kinds
+}
+
+func TestLintTier(t *testing.T) {
+	global := NamedTier{Name: "global", Tier: Tier{
+		Allow: []string{"Bash(git *)", "Bash(go test:*)"},
+		Ask:   []string{"Bash(git push:*)"},
+		Deny:  []string{"Bash(rm -rf:*)"},
+	}}
+	repo := NamedTier{Name: "proj", Tier: Tier{
+		Allow: []string{
+			"Bash(go test:*)",
+			"Bash(git status)",
+			"Bash(make:*)",
+			"Bash(make lint)",
+			"Bash(git push origin)",
+			"Bash(rm -rf build)",
+			"Bash(*)",
+			"Bash()",
+		},
+		Ask:  []string{},
+		Deny: []string{},
+	}}
+
+	got := lintKinds(LintTier(repo, &global))
+	want := map[string]string{
+		"allow Bash(go test:*)":       LintDuplicate,
+		"allow Bash(git status)":      LintRedundant,
+		"allow Bash(make lint)":       LintRedundant,
+		"allow Bash(git push origin)": LintRedundant,
+		"allow Bash(rm -rf build)":    LintShadowed,
+		"allow Bash(*)":               LintOverbroad,
+		"allow Bash()":                LintInvalid,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LintTier() kinds = %v, want %v", got, want)
+	}
+}
+
+func TestLintTierShadowedWithinTier(t *testing.T) {
+	global := NamedTier{Name: "global", Tier: Tier{
+		Allow: []string{"Bash(git *)", "Bash(git *)"},
+		Ask:   []string{"Bash(git push:*)"},
+		Deny:  []string{},
+	}}
+
+	problems := LintTier(global, nil)
+	if len(problems) != 1 || problems[0].Kind != LintDuplicate || problems[0].Index != 1 {
+		t.Fatalf("expected only the second Bash(git *) as a duplicate, got %+v", problems)
+	}
+
+	global.Ask = []string{"Bash(git push:*)", "Bash(git push origin main)"}
+	global.Deny = []string{"Bash(git push --force:*)"}
+	global.Allow = []string{"Bash(git push --force origin)"}
+
+	got := lintKinds(LintTier(global, nil))
+	want := map[string]string{
+		"ask Bash(git push origin main)":      LintRedundant,
+		"allow Bash(git push --force origin)": LintShadowed,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LintTier() kinds = %v, want %v", got, want)
+	}
+}
+
+func TestLintTierIgnoresExpiringCoverers(t *testing.T) {
+	later := time.Now().Add(24 * time.Hour)
+
+	global := NamedTier{Name: "global", Tier: Tier{
+		Allow: []string{"Bash(git status)"},
+		Ask:   []string{},
+		Deny:  []string{"Bash(rm -rf:*)"},
+	}}
+	global.SetInfo(ListAllow, "Bash(git status)", RuleInfo{Expires: later})
+	global.SetInfo(ListDeny, "Bash(rm -rf:*)", RuleInfo{Expires: later})
+
+	repo := NamedTier{Name: "proj", Tier: Tier{
+		Allow: []string{"Bash(go test:*)", "Bash(go test ./...)", "Bash(git status)", "Bash(rm -rf build)"},
+		Ask:   []string{},
+		Deny:  []string{},
+	}}
+	repo.SetInfo(ListAllow, "Bash(go test:*)", RuleInfo{Expires: later})
+
+	problems := LintTier(repo, &global)
+	if len(problems) != 0 {
+		t.Errorf("expected rules covered only by expiring rules to be kept, got %+v", problems)
+	}
+	if fixed := FixTier(repo.Tier, problems); !reflect.DeepEqual(fixed.Allow, repo.Allow) {
+		t.Errorf("FixTier() allow = %v, want %v", fixed.Allow, repo.Allow)
+	}
+}
+
+func TestFixTier(t *testing.T) {
+	tier := Tier{
+		Allow: []string{"Bash(git *)", "Bash(git status)", "Bash(git *)", "Bash"},
+		Ask:   []string{},
+		Deny:  []string{},
+	}
+
+	fixed := FixTier(tier, LintTier(NamedTier{Name: "global", Tier: tier}, nil))
+	want := []string{"Bash(git *)", "Bash"}
+	if !reflect.DeepEqual(fixed.Allow, want) {
+		t.Errorf("FixTier() allow = %v, want %v", fixed.Allow, want)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tiersDir, "repos", "github.com", "owner"), 0o755)
+	globalPath := filepath.Join(tiersDir, "global.json")
+	repoPath := filepath.Join(tiersDir, "repos", "github.com", "owner", "proj.json")
+
+	SaveTierFile(globalPath, Tier{Allow: []string{"Bash(git *)"}, Ask: []string{}, Deny: []string{}})
+	SaveTierFile(repoPath, Tier{Allow: []string{"Bash(git log)", "Bash()"}, Ask: []string{}, Deny: []string{}})
+
+	var out bytes.Buffer
+	if err := RunLint(tiersDir, &out, false); err == nil {
+		t.Error("expected an error for a tier with problems")
+	}
+	for _, line := range []string{
+		"ok 1 - global",
+		"not ok 2 - github.com/owner/proj allow Bash()",
+		"not ok 3 - github.com/owner/proj allow Bash(git log)",
+		"1..3",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %q in output:\n%s", line, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := RunLint(tiersDir, &out, true); err == nil {
+		t.Error("expected the invalid rule to remain a problem after --fix")
+	}
+	if !strings.Contains(out.String(), "ok 3 - github.com/owner/proj allow Bash(git log) # removed") {
+		t.Errorf("expected the redundant rule to be removed, got:\n%s", out.String())
+	}
+
+	repo, _ := LoadTierFile(repoPath)
+	if !reflect.DeepEqual(repo.Allow, []string{"Bash()"}) {
+		t.Errorf("expected only the invalid rule left, got %v", repo.Allow)
+	}
+}
@@ -1,30 +1,84 @@
 package perms
 
 import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
 )
 
+// toolArgs names the input field each known tool is matched on.
+var toolArgs = map[string]string{
+	"Bash":         "command",
+	"Read":         "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+	"Glob":         "path",
+	"Grep":         "path",
+	"WebFetch":     "url",
+	"WebSearch":    "query",
+}
+
 // BuildPermissionString constructs the permission string Claude Code would use
-// for a tool invocation. For Bash: Bash(<command>). For Read/Edit/Write with
-// file_path: ToolName(/path). For WebFetch with url: WebFetch(<url>).
-// Otherwise just the tool name.
+// for a tool invocation: ToolName(<argument>), where the argument is the
+// field listed in toolArgs, e.g. Bash(<command>), Edit(/path),
+// NotebookEdit(<notebook_path>), WebFetch(<url>) or WebSearch(<query>). Glob
+// and Grep use the directory they search, which is "." for the working
+// directory when no path is given.
+//
+// MCP tools (mcp__<server>__<tool>) are just the tool name, since their
+// rules match by name. Any other tool uses the generic form
+// ToolName(key=value, ...) over its string, number and boolean input fields
+// in key order, or just the tool name when it has none.
 func BuildPermissionString(toolName string, toolInput map[string]any) string {
-	switch toolName {
-	case "Bash":
-		if cmd, ok := stringField(toolInput, "command"); ok {
-			return toolName + "(" + cmd + ")"
+	if arg, ok := toolArgument(toolName, toolInput); ok {
+		return toolName + "(" + arg + ")"
+	}
+
+	return toolName
+}
+
+// toolArgument returns the argument rules for toolName are matched against.
+func toolArgument(toolName string, toolInput map[string]any) (string, bool) {
+	if field, ok := toolArgs[toolName]; ok {
+		if arg, ok := stringField(toolInput, field); ok {
+			return arg, true
 		}
-	case "Read", "Edit", "Write":
-		if fp, ok := stringField(toolInput, "file_path"); ok {
-			return toolName + "(" + fp + ")"
+		if toolName == "Glob" || toolName == "Grep" {
+			return ".", true
 		}
-	case "WebFetch":
-		if u, ok := stringField(toolInput, "url"); ok {
-			return toolName + "(" + u + ")"
+		return "", false
+	}
+
+	if strings.HasPrefix(toolName, mcpPrefix) {
+		return "", false
+	}
+
+	return genericArgument(toolInput)
+}
+
+func genericArgument(toolInput map[string]any) (string, bool) {
+	keys := make([]string, 0, len(toolInput))
+	for key := range toolInput {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var fields []string
+	for _, key := range keys {
+		switch v := toolInput[key].(type) {
+		case string, float64, int, bool:
+			fields = append(fields, fmt.Sprintf("%s=%v", key, v))
 		}
 	}
+	if len(fields) == 0 {
+		return "", false
+	}
 
-	return toolName
+	return strings.Join(fields, ", "), true
 }
 
 // MatchesAnyRule checks if a tool invocation matches any rule in the list.
@@ -36,8 +90,14 @@ func MatchesAnyRule(rules []string, toolName string, toolInput map[string]any) b
 // MatchingRule returns the first rule that matches the tool invocation, or
 // empty string and false if none match.
 func MatchingRule(rules []string, toolName string, toolInput map[string]any) (string, bool) {
+	return MatchingRuleIn(rules, toolName, toolInput, PathVars{})
+}
+
+// MatchingRuleIn is MatchingRule with file rule patterns resolved against
+// vars, so that relative paths and placeholders like $WORKTREE can match.
+func MatchingRuleIn(rules []string, toolName string, toolInput map[string]any, vars PathVars) (string, bool) {
 	for _, rule := range rules {
-		if matchRule(rule, toolName, toolInput) {
+		if matchRule(rule, toolName, toolInput, vars) {
 			return rule, true
 		}
 	}
@@ -46,10 +106,10 @@ func MatchingRule(rules []string, toolName string, toolInput map[string]any) (st
 }
 
 // matchRule checks if a single rule matches the tool invocation.
-func matchRule(rule string, toolName string, toolInput map[string]any) bool {
+func matchRule(rule string, toolName string, toolInput map[string]any, vars PathVars) bool {
 	ruleTool, rulePattern := parseRule(rule)
 
-	if ruleTool != toolName {
+	if !matchToolName(ruleTool, toolName) {
 		return false
 	}
 
@@ -57,10 +117,55 @@ func matchRule(rule string, toolName string, toolInput map[string]any) bool {
 		return true
 	}
 
-	permStr := BuildPermissionString(toolName, toolInput)
-	_, invocationArg := parseRule(permStr)
+	arg, ok := toolArgument(toolName, toolInput)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case fileTools[toolName]:
+		return matchPath(rulePattern, arg, vars)
+	case toolName == "WebFetch" && strings.HasPrefix(rulePattern, domainPrefix):
+		return matchDomain(strings.TrimPrefix(rulePattern, domainPrefix), arg)
+	}
+
+	return matchPattern(rulePattern, arg)
+}
+
+const (
+	mcpPrefix    = "mcp__"
+	domainPrefix = "domain:"
+)
+
+// matchToolName matches the tool of a rule against a tool name. MCP rules
+// may name a whole server, as mcp__github or mcp__github__*, or end in * to
+// cover tools sharing a prefix.
+func matchToolName(ruleTool, toolName string) bool {
+	if ruleTool == toolName {
+		return true
+	}
+	if !strings.HasPrefix(ruleTool, mcpPrefix) || !strings.HasPrefix(toolName, mcpPrefix) {
+		return false
+	}
+
+	if prefix, ok := strings.CutSuffix(ruleTool, "*"); ok {
+		return strings.HasPrefix(toolName, prefix)
+	}
+
+	server := strings.TrimPrefix(ruleTool, mcpPrefix)
+	return !strings.Contains(server, "__") && strings.HasPrefix(toolName, ruleTool+"__")
+}
+
+// matchDomain matches the host of rawURL against a domain pattern such as
+// pkg.go.dev or *.github.com.
+func matchDomain(pattern, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
 
-	return matchPattern(rulePattern, invocationArg)
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(u.Hostname()))
+	return ok
 }
 
 // parseRule splits a rule like "Bash(git *)" into tool name "Bash" and
@@ -80,21 +185,17 @@ func parseRule(rule string) (string, string) {
 
 // matchPattern matches a command against a rule pattern. Supports three forms:
 //   - "git status" -- exact match
-//   - "git *" -- trailing space-star: command must start with "git "
 //   - "go test:*" -- colon-star: command must start with "go test" (the prefix
 //     before ":*"), matching "go test", "go test ./...", etc.
+//   - "git *", "/src/*", "git push --force*" -- trailing star: command must
+//     start with everything before the "*"
 func matchPattern(pattern string, command string) bool {
-	if strings.HasSuffix(pattern, "/*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(command, prefix)
-	}
-
 	if strings.HasSuffix(pattern, ":*") {
 		prefix := strings.TrimSuffix(pattern, ":*")
 		return command == prefix || strings.HasPrefix(command, prefix+" ")
 	}
 
-	if strings.HasSuffix(pattern, " *") {
+	if strings.HasSuffix(pattern, "*") {
 		prefix := strings.TrimSuffix(pattern, "*")
 		return strings.HasPrefix(command, prefix)
 	}
@@ -88,6 +88,18 @@ func TestMatchBashTrailingWildcard(t *testing.T) {
 	}
 }
 
+func TestMatchBashBareTrailingWildcard(t *testing.T) {
+	rules := []string{"Bash(git push --force*)"}
+
+	if !MatchesAnyRule(rules, "Bash", map[string]any{"command": "git push --force-with-lease"}) {
+		t.Error("expected 'git push --force-with-lease' to match 'Bash(git push --force*)'")
+	}
+
+	if MatchesAnyRule(rules, "Bash", map[string]any{"command": "git push origin"}) {
+		t.Error("expected 'git push origin' not to match 'Bash(git push --force*)'")
+	}
+}
+
 func TestBuildPermissionString(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -131,6 +143,36 @@ func TestBuildPermissionString(t *testing.T) {
 			toolInput: map[string]any{"url": "https://example.com"},
 			want:      "WebFetch(https://example.com)",
 		},
+		{
+			name:      "notebook edit",
+			toolName:  "NotebookEdit",
+			toolInput: map[string]any{"notebook_path": "/tmp/a.ipynb", "new_source": "x"},
+			want:      "NotebookEdit(/tmp/a.ipynb)",
+		},
+		{
+			name:      "grep with path",
+			toolName:  "Grep",
+			toolInput: map[string]any{"pattern": "TODO", "path": "/src"},
+			want:      "Grep(/src)",
+		},
+		{
+			name:      "glob in working directory",
+			toolName:  "Glob",
+			toolInput: map[string]any{"pattern": "**/*.go"},
+			want:      "Glob(.)",
+		},
+		{
+			name:      "web search",
+			toolName:  "WebSearch",
+			toolInput: map[string]any{"query": "golang flock"},
+			want:      "WebSearch(golang flock)",
+		},
+		{
+			name:      "unknown tool",
+			toolName:  "Task",
+			toolInput: map[string]any{"subagent_type": "Explore", "description": "find it", "opts": map[string]any{}},
+			want:      "Task(description=find it, subagent_type=Explore)",
+		},
 		{
 			name:      "mcp tool",
 			toolName:  "mcp__plugin_nix_nix__build",
@@ -214,3 +256,83 @@ func TestMatchingRuleName(t *testing.T) {
 		t.Error("expected no match for Write")
 	}
 }
+
+func TestMatchFileToolsOnPath(t *testing.T) {
+	rules := []string{"Grep(/src/**)", "Glob(/src/**)", "NotebookEdit(/nb/*.ipynb)"}
+
+	if !MatchesAnyRule(rules, "Grep", map[string]any{"pattern": "x", "path": "/src/app"}) {
+		t.Error("expected Grep under /src to match")
+	}
+	if MatchesAnyRule(rules, "Grep", map[string]any{"pattern": "x", "path": "/etc"}) {
+		t.Error("expected Grep of /etc not to match")
+	}
+	if MatchesAnyRule(rules, "Glob", map[string]any{"pattern": "*.go"}) {
+		t.Error("expected Glob of an unknown working directory not to match")
+	}
+
+	_, ok := MatchingRuleIn(rules, "Glob", map[string]any{"pattern": "*.go"}, PathVars{CWD: "/src/app"})
+	if !ok {
+		t.Error("expected Glob in a working directory under /src to match")
+	}
+	if !MatchesAnyRule(rules, "NotebookEdit", map[string]any{"notebook_path": "/nb/a.ipynb"}) {
+		t.Error("expected NotebookEdit to match on notebook_path")
+	}
+}
+
+func TestMatchWebFetchDomain(t *testing.T) {
+	rules := []string{"WebFetch(domain:pkg.go.dev)", "WebFetch(domain:*.github.com)"}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://pkg.go.dev/net/url", true},
+		{"https://PKG.go.dev:443/x", true},
+		{"https://evil.pkg.go.dev/x", false},
+		{"https://pkg.go.dev.evil.com/x", false},
+		{"https://api.github.com/repos", true},
+		{"https://github.com/", false},
+		{"not a url", false},
+	}
+
+	for _, tt := range tests {
+		got := MatchesAnyRule(rules, "WebFetch", map[string]any{"url": tt.url})
+		if got != tt.want {
+			t.Errorf("WebFetch(%s) matched = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMatchMCPWildcards(t *testing.T) {
+	tests := []struct {
+		rule string
+		tool string
+		want bool
+	}{
+		{"mcp__github__*", "mcp__github__create_issue", true},
+		{"mcp__github__*", "mcp__gitlab__create_issue", false},
+		{"mcp__github", "mcp__github__create_issue", true},
+		{"mcp__github", "mcp__github_enterprise__create_issue", false},
+		{"mcp__github__get_*", "mcp__github__get_issue", true},
+		{"mcp__github__get_*", "mcp__github__create_issue", false},
+		{"Bash*", "Bash", false},
+	}
+
+	for _, tt := range tests {
+		got := MatchesAnyRule([]string{tt.rule}, tt.tool, nil)
+		if got != tt.want {
+			t.Errorf("rule %s on %s matched = %v, want %v", tt.rule, tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWebSearchAndGenericTools(t *testing.T) {
+	if !MatchesAnyRule([]string{"WebSearch(golang *)"}, "WebSearch", map[string]any{"query": "golang flock"}) {
+		t.Error("expected WebSearch to match on its query")
+	}
+
+	rules := []string{"Task(description=*)"}
+	if !MatchesAnyRule(rules, "Task", map[string]any{"description": "x", "prompt": "y"}) {
+		t.Error("expected generic form to match a prefix pattern")
+	}
+}
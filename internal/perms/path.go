@@ -0,0 +1,200 @@
+package perms
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/git"
+)
+
+// fileTools are the tools whose rule patterns are paths, matched with
+// matchPath rather than as command prefixes.
+var fileTools = map[string]bool{
+	"Read": true, "Edit": true, "MultiEdit": true, "Write": true,
+	"NotebookEdit": true, "Glob": true, "Grep": true,
+}
+
+// Placeholders usable in file rule patterns, so that a single global rule
+// like Edit($WORKTREE/**) applies to whichever worktree a session runs in.
+const (
+	WorktreeVar = "$WORKTREE"
+	RepoVar     = "$REPO"
+)
+
+// PathVars are the values file rule patterns are resolved with: the
+// session's working directory, the checkout containing it and the repo that
+// checkout belongs to. Empty values leave rules that need them unmatched.
+type PathVars struct {
+	CWD      string
+	Worktree string
+	Repo     string
+}
+
+// ResolvePathVars derives PathVars from the working directory of a hook
+// payload.
+func ResolvePathVars(cwd string) PathVars {
+	vars := PathVars{CWD: cwd}
+	if cwd == "" {
+		return vars
+	}
+
+	root, err := git.Run(cwd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return vars
+	}
+	vars.Worktree = root
+
+	if repo, err := git.CommonDir(root); err == nil {
+		vars.Repo = repo
+	}
+	return vars
+}
+
+// expandPattern resolves a file rule pattern to an absolute one. It
+// substitutes $WORKTREE and $REPO, expands ~, treats // and / prefixes as
+// absolute and anything else as relative to vars.CWD. It reports false when
+// the pattern needs a value vars does not have.
+func expandPattern(pattern string, vars PathVars) (string, bool) {
+	for placeholder, value := range map[string]string{WorktreeVar: vars.Worktree, RepoVar: vars.Repo} {
+		braced := "${" + placeholder[1:] + "}"
+		if !strings.Contains(pattern, placeholder) && !strings.Contains(pattern, braced) {
+			continue
+		}
+		if value == "" {
+			return "", false
+		}
+		pattern = strings.ReplaceAll(pattern, braced, value)
+		pattern = strings.ReplaceAll(pattern, placeholder, value)
+	}
+
+	pattern, ok := expandHome(pattern)
+	if !ok {
+		return "", false
+	}
+
+	if !filepath.IsAbs(pattern) {
+		if vars.CWD == "" {
+			return "", false
+		}
+		pattern = filepath.Join(vars.CWD, pattern)
+	}
+
+	return pattern, true
+}
+
+func expandHome(p string) (string, bool) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, true
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	// Not filepath.Join, which would apply .. before canonicalPath can
+	// resolve the symlinks it follows.
+	return home + p[1:], true
+}
+
+// matchPath reports whether the file at target matches a file rule pattern,
+// using gitignore-style globs: * and ? match within a path segment, ** matches
+// any number of segments, and a pattern matching a directory matches
+// everything inside it. Both sides have symlinks resolved, before any .. is
+// applied, and are cleaned first, so neither .. nor a symlink can lead a
+// path out of the directory a rule covers.
+func matchPath(pattern, target string, vars PathVars) bool {
+	pattern, ok := expandPattern(pattern, vars)
+	if !ok {
+		return false
+	}
+
+	target, ok = expandHome(target)
+	if !ok {
+		return false
+	}
+	if !filepath.IsAbs(target) {
+		if vars.CWD == "" {
+			return false
+		}
+		target = vars.CWD + string(filepath.Separator) + target
+	}
+
+	patternSegs := splitPath(canonicalPattern(pattern))
+	targetSegs := splitPath(canonicalPath(target))
+
+	for n := len(targetSegs); n > 0; n-- {
+		if matchSegments(patternSegs, targetSegs[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath resolves symlinks in as much of p as exists, since a file
+// about to be written need not exist yet, and cleans the rest. Symlinks are
+// resolved before .. is applied, as the kernel does, so that link/.. is the
+// parent of the link's target rather than the directory holding the link.
+func canonicalPath(p string) string {
+	segs := strings.Split(filepath.ToSlash(p), "/")
+
+	for n := len(segs); n > 0; n-- {
+		prefix := strings.Join(segs[:n], "/")
+		if prefix == "" {
+			prefix = "/"
+		}
+		if resolved, err := filepath.EvalSymlinks(filepath.FromSlash(prefix)); err == nil {
+			return filepath.Join(append([]string{resolved}, segs[n:]...)...)
+		}
+	}
+	return filepath.Clean(p)
+}
+
+// canonicalPattern canonicalizes the literal directories leading up to the
+// first segment of pattern with glob characters.
+func canonicalPattern(pattern string) string {
+	segs := splitPath(filepath.Clean(pattern))
+
+	literal := 0
+	for literal < len(segs) && !strings.ContainsAny(segs[literal], "*?[") {
+		literal++
+	}
+	if literal == 0 {
+		return pattern
+	}
+
+	prefix := canonicalPath("/" + strings.Join(segs[:literal], "/"))
+	return filepath.Join(append([]string{prefix}, segs[literal:]...)...)
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(filepath.ToSlash(p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:])
+}
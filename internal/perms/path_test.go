@@ -0,0 +1,110 @@
+package perms
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPathGlobs(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"//src/app/**", "/src/app/main.go", true},
+		{"//src/app/**", "/src/app/internal/x/y.go", true},
+		{"//src/app/**", "/src/other/main.go", false},
+		{"/src/app/*.go", "/src/app/main.go", true},
+		{"/src/app/*.go", "/src/app/main.rs", false},
+		{"/src/**/*_test.go", "/src/app/deep/x_test.go", true},
+		{"/src/**/*_test.go", "/src/x_test.go", true},
+		{"/src/**/*_test.go", "/src/app/x.go", false},
+		{"/src/app", "/src/app/main.go", true},
+		{"/src/app", "/src/application/main.go", false},
+		{"/src/app/**", "/src/app/../../etc/passwd", false},
+		{"/etc/*", "/src/app/../../etc/passwd", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.target, PathVars{}); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPathHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	rules := []string{"Read(~/eng/**)"}
+	if !MatchesAnyRule(rules, "Read", map[string]any{"file_path": filepath.Join(home, "eng", "x.go")}) {
+		t.Error("expected ~ to expand to the home directory")
+	}
+	if MatchesAnyRule(rules, "Read", map[string]any{"file_path": filepath.Join(home, ".ssh", "id_ed25519")}) {
+		t.Error("expected file outside ~/eng not to match")
+	}
+}
+
+func TestMatchPathSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	worktree := filepath.Join(dir, "wt")
+	secrets := filepath.Join(dir, "secrets")
+	os.MkdirAll(worktree, 0o755)
+	os.MkdirAll(secrets, 0o755)
+	os.Symlink(secrets, filepath.Join(worktree, "link"))
+
+	rules := []string{"Edit(//" + worktree + "/**)"}
+
+	if !MatchesAnyRule(rules, "Edit", map[string]any{"file_path": filepath.Join(worktree, "new.go")}) {
+		t.Error("expected new file in worktree to match")
+	}
+	if MatchesAnyRule(rules, "Edit", map[string]any{"file_path": filepath.Join(worktree, "link", "key")}) {
+		t.Error("expected path through a symlink out of the worktree not to match")
+	}
+
+	// The kernel resolves link before .., so this is a sibling of secrets.
+	escape := filepath.Join(worktree, "link") + "/../x"
+	if MatchesAnyRule(rules, "Edit", map[string]any{"file_path": escape}) {
+		t.Errorf("expected %s not to match", escape)
+	}
+	if matchPath("//"+worktree+"/**", "link/../x", PathVars{CWD: worktree}) {
+		t.Error("expected a relative link/.. path not to match")
+	}
+	if !MatchesAnyRule(rules, "Edit", map[string]any{"file_path": worktree + "/sub/../new.go"}) {
+		t.Error("expected .. within the worktree to match")
+	}
+}
+
+func TestMatchPathPlaceholders(t *testing.T) {
+	vars := PathVars{
+		CWD:      "/eng/worktrees/repo/feature/sub",
+		Worktree: "/eng/worktrees/repo/feature",
+		Repo:     "/eng/repos/repo",
+	}
+	rules := []string{"Edit($WORKTREE/**)", "Read(${REPO}/docs/**)", "Write(out/*)"}
+
+	tests := []struct {
+		tool   string
+		target string
+		want   bool
+	}{
+		{"Edit", "/eng/worktrees/repo/feature/main.go", true},
+		{"Edit", "/eng/worktrees/repo/other/main.go", false},
+		{"Read", "/eng/repos/repo/docs/x.md", true},
+		{"Read", "/eng/repos/repo/main.go", false},
+		{"Write", "/eng/worktrees/repo/feature/sub/out/a.txt", true},
+		{"Write", "/eng/worktrees/repo/feature/out/a.txt", false},
+	}
+
+	for _, tt := range tests {
+		_, got := MatchingRuleIn(rules, tt.tool, map[string]any{"file_path": tt.target}, vars)
+		if got != tt.want {
+			t.Errorf("%s(%s) matched = %v, want %v", tt.tool, tt.target, got, tt.want)
+		}
+	}
+
+	if MatchesAnyRule(rules, "Edit", map[string]any{"file_path": "/eng/worktrees/repo/feature/main.go"}) {
+		t.Error("expected placeholder rules not to match without a worktree")
+	}
+}
@@ -0,0 +1,264 @@
+package perms
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/amarbel-llc/sweatshop/internal/tap"
+)
+
+// Request is a tool invocation to decide, as sent in a PermissionRequest
+// hook payload.
+type Request struct {
+	ToolName  string         `json:"tool_name"`
+	ToolInput map[string]any `json:"tool_input"`
+	CWD       string         `json:"cwd"`
+}
+
+// Evaluation is what perms check concludes about a Request.
+type Evaluation struct {
+	// Permission is the request as a permission string, e.g. Bash(ls).
+	Permission string
+	// Repos are the repo tiers that applied, most specific first.
+	Repos    []string
+	Decision Decision
+	// Decided is false when no rule matched, leaving the prompt to Claude.
+	Decided bool
+}
+
+// Outcome is the Behavior of the decision, or DecisionNone.
+func (e Evaluation) Outcome() string {
+	if !e.Decided {
+		return DecisionNone
+	}
+	return e.Decision.Behavior
+}
+
+// Evaluate decides req against the tiers in tiersDir the way perms check
+// does. The repo tiers are those of req.CWD unless repos is given. Unlike
+// the hook, it fails on a corrupt tier file rather than moving it aside.
+func Evaluate(tiersDir string, req Request, repos []string) (Evaluation, error) {
+	return evaluate(tiersDir, req, repos, LoadTierFile)
+}
+
+// evaluate is Evaluate loading each tier file with load.
+func evaluate(tiersDir string, req Request, repos []string, load func(string) (Tier, error)) (Evaluation, error) {
+	vars := ResolvePathVars(req.CWD)
+	if repos == nil {
+		repos = repoTierNamesFromCWD(req.CWD, vars)
+	}
+
+	tiers, err := loadTiers(tiersDir, repos, load)
+	if err != nil {
+		return Evaluation{}, err
+	}
+
+	decision, ok := Decide(tiers, req.ToolName, req.ToolInput, vars)
+	return Evaluation{
+		Permission: BuildPermissionString(req.ToolName, req.ToolInput),
+		Repos:      repos,
+		Decision:   decision,
+		Decided:    ok,
+	}, nil
+}
+
+// ParsePermission turns a permission string such as "Bash(git push)" or
+// "Read(/etc/hosts)" back into the tool name and input that produce it.
+// Only tools matched on a single input field, and bare tool names, can be
+// parsed.
+func ParsePermission(permission string) (string, map[string]any, error) {
+	if err := validateToolName(strings.SplitN(permission, "(", 2)[0]); err != nil {
+		return "", nil, err
+	}
+
+	tool, arg := parseRule(permission)
+	if arg == "" {
+		return tool, map[string]any{}, nil
+	}
+
+	field, ok := toolArgs[tool]
+	if !ok {
+		return "", nil, fmt.Errorf("cannot tell which input field of %s the argument is", tool)
+	}
+	return tool, map[string]any{field: arg}, nil
+}
+
+// TestCase is a tool invocation and the decision the tiers should make for
+// it, read from a [[case]] table of a test file. The invocation is either a
+// permission string, or a tool and its input.
+type TestCase struct {
+	Name       string         `toml:"name"`
+	Permission string         `toml:"permission"`
+	Tool       string         `toml:"tool"`
+	Input      map[string]any `toml:"input"`
+	CWD        string         `toml:"cwd"`
+	// Repos, when set, are the repo tiers to apply instead of those of CWD,
+	// so that cases need not run inside a checkout.
+	Repos []string `toml:"repos"`
+	// Expect is allow, ask, deny or none.
+	Expect string `toml:"expect"`
+	// Rule, when set, is the rule the decision must come from.
+	Rule string `toml:"rule"`
+}
+
+type testFile struct {
+	Cases []TestCase `toml:"case"`
+}
+
+// ParseTestCases reads [[case]] tables and validates them. Relative working
+// directories are taken relative to dir.
+func ParseTestCases(data []byte, dir string) ([]TestCase, error) {
+	var tf testFile
+	if err := toml.Unmarshal(data, &tf); err != nil {
+		return nil, err
+	}
+
+	for i := range tf.Cases {
+		c := &tf.Cases[i]
+		label := fmt.Sprintf("case %d", i+1)
+		if c.Name != "" {
+			label += " (" + c.Name + ")"
+		}
+
+		switch {
+		case c.Permission != "" && c.Tool != "":
+			return nil, fmt.Errorf("%s: set either permission or tool, not both", label)
+		case c.Permission != "":
+			tool, input, err := ParsePermission(c.Permission)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", label, err)
+			}
+			c.Tool, c.Input = tool, input
+		case c.Tool == "":
+			return nil, fmt.Errorf("%s: missing permission or tool", label)
+		}
+
+		switch c.Expect {
+		case ListAllow, ListAsk, ListDeny, DecisionNone:
+		case "":
+			return nil, fmt.Errorf("%s: missing expect", label)
+		default:
+			return nil, fmt.Errorf("%s: invalid expect %q: want allow, ask, deny or none", label, c.Expect)
+		}
+
+		if c.CWD != "" && !filepath.IsAbs(c.CWD) {
+			c.CWD = filepath.Join(dir, c.CWD)
+		}
+	}
+
+	return tf.Cases, nil
+}
+
+func LoadTestCases(path string) ([]TestCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTestCases(data, filepath.Dir(abs))
+}
+
+// RunTests evaluates each case against the tiers in tiersDir and reports
+// TAP to w, naming the rule behind each decision.
+func RunTests(tiersDir string, cases []TestCase, w io.Writer) error {
+	tw := tap.NewWriter(w)
+	failed := 0
+
+	for _, c := range cases {
+		req := Request{ToolName: c.Tool, ToolInput: c.Input, CWD: c.CWD}
+
+		desc := c.Name
+		if desc == "" {
+			desc = BuildPermissionString(c.Tool, c.Input)
+		}
+
+		ev, err := Evaluate(tiersDir, req, c.Repos)
+		if err != nil {
+			failed++
+			tw.NotOk(desc, map[string]string{"message": err.Error(), "severity": "fail"})
+			continue
+		}
+
+		got := ev.Outcome()
+		if got == c.Expect && (c.Rule == "" || c.Rule == ev.Decision.Rule) {
+			tw.Ok(fmt.Sprintf("%s # %s", desc, describeOutcome(ev)))
+			continue
+		}
+
+		failed++
+		diag := map[string]string{
+			"expected": c.Expect,
+			"got":      got,
+			"severity": "fail",
+		}
+		if c.Rule != "" {
+			diag["expected rule"] = c.Rule
+		}
+		if ev.Decided {
+			diag["rule"] = fmt.Sprintf("%s (%s tier)", ev.Decision.Rule, ev.Decision.Tier)
+		}
+		if len(ev.Decision.Parts) > 0 || ev.Decision.ParseErr != nil {
+			var explanation strings.Builder
+			writeParts(&explanation, ev.Decision)
+			diag["parts"] = explanation.String()
+		}
+		tw.NotOk(desc, diag)
+	}
+
+	tw.Plan()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d policy test(s) failed", failed, len(cases))
+	}
+	return nil
+}
+
+func describeOutcome(ev Evaluation) string {
+	if !ev.Decided {
+		return "none: no rule matches"
+	}
+	return fmt.Sprintf("%s by %s (%s tier)", ev.Decision.Behavior, ev.Decision.Rule, ev.Decision.Tier)
+}
+
+// writeParts describes how a Bash command was split and which allow rule,
+// if any, covers each simple command.
+func writeParts(w io.Writer, decision Decision) {
+	if decision.ParseErr != nil {
+		fmt.Fprintf(w, "cannot split command: %v\n", decision.ParseErr)
+	}
+	for _, part := range decision.Parts {
+		if part.Rule == "" {
+			fmt.Fprintf(w, "%s: no allow rule matches\n", part.Command)
+		} else {
+			fmt.Fprintf(w, "%s: %s (%s tier)\n", part.Command, part.Rule, part.Tier)
+		}
+	}
+}
+
+// Explain writes a readable account of ev: the tiers consulted, how a Bash
+// command was split and the resulting decision.
+func Explain(w io.Writer, ev Evaluation) {
+	fmt.Fprintf(w, "permission: %s\n", ev.Permission)
+	tiers := append(append([]string{}, ev.Repos...), "global")
+	fmt.Fprintf(w, "tiers:      %s\n", strings.Join(tiers, ", "))
+
+	if len(ev.Decision.Parts) > 0 || ev.Decision.ParseErr != nil {
+		fmt.Fprintln(w, "parts:")
+		var parts strings.Builder
+		writeParts(&parts, ev.Decision)
+		for _, line := range strings.Split(strings.TrimSuffix(parts.String(), "\n"), "\n") {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+
+	fmt.Fprintf(w, "decision:   %s\n", describeOutcome(ev))
+}
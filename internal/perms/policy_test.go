@@ -0,0 +1,181 @@
+package perms
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePermission(t *testing.T) {
+	tests := []struct {
+		permission string
+		tool       string
+		input      map[string]any
+	}{
+		{"Bash(git push)", "Bash", map[string]any{"command": "git push"}},
+		{"Read(/etc/hosts)", "Read", map[string]any{"file_path": "/etc/hosts"}},
+		{"WebFetch(https://go.dev)", "WebFetch", map[string]any{"url": "https://go.dev"}},
+		{"mcp__github__get_issue", "mcp__github__get_issue", map[string]any{}},
+	}
+
+	for _, tt := range tests {
+		tool, input, err := ParsePermission(tt.permission)
+		if err != nil {
+			t.Errorf("ParsePermission(%q): unexpected error: %v", tt.permission, err)
+			continue
+		}
+		if tool != tt.tool || !reflect.DeepEqual(input, tt.input) {
+			t.Errorf("ParsePermission(%q) = %q, %v, want %q, %v", tt.permission, tool, input, tt.tool, tt.input)
+		}
+		if got := BuildPermissionString(tool, input); got != tt.permission {
+			t.Errorf("BuildPermissionString round trip of %q gave %q", tt.permission, got)
+		}
+	}
+
+	for _, bad := range []string{"Task(description=x)", "Bash (ls)"} {
+		if _, _, err := ParsePermission(bad); err == nil {
+			t.Errorf("ParsePermission(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestParseTestCases(t *testing.T) {
+	data := []byte(`
+[[case]]
+name = "push asks"
+permission = "Bash(git push)"
+repos = ["myrepo"]
+expect = "ask"
+
+[[case]]
+tool = "Edit"
+input = { file_path = "src/main.go" }
+cwd = "proj"
+expect = "allow"
+`)
+
+	cases, err := ParseTestCases(data, "/cases")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cases) != 2 {
+		t.Fatalf("expected 2 cases, got %d", len(cases))
+	}
+	if cases[0].Tool != "Bash" || cases[0].Input["command"] != "git push" {
+		t.Errorf("expected the permission to be parsed, got %+v", cases[0])
+	}
+	if cases[1].CWD != "/cases/proj" {
+		t.Errorf("expected cwd relative to the cases file, got %q", cases[1].CWD)
+	}
+
+	for _, bad := range []string{
+		`[[case]]
+expect = "allow"`,
+		`[[case]]
+permission = "Bash(ls)"`,
+		`[[case]]
+permission = "Bash(ls)"
+expect = "maybe"`,
+		`[[case]]
+permission = "Bash(ls)"
+tool = "Bash"
+expect = "allow"`,
+	} {
+		if _, err := ParseTestCases([]byte(bad), "/"); err == nil {
+			t.Errorf("expected an error for:\n%s", bad)
+		}
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tiersDir, "repos"), 0o755)
+	SaveTierFile(filepath.Join(tiersDir, "global.json"), Tier{
+		Allow: []string{"Bash(git *)"},
+		Ask:   []string{"Bash(git push:*)"},
+		Deny:  []string{},
+	})
+	SaveTierFile(filepath.Join(tiersDir, "repos", "myrepo.json"), Tier{
+		Allow: []string{"Bash(make:*)"},
+		Ask:   []string{},
+		Deny:  []string{},
+	})
+
+	cases := []TestCase{
+		{Tool: "Bash", Input: map[string]any{"command": "git status"}, Expect: ListAllow, Rule: "Bash(git *)"},
+		{Name: "push asks", Tool: "Bash", Input: map[string]any{"command": "git push"}, Expect: ListAsk},
+		{Tool: "Bash", Input: map[string]any{"command": "make lint"}, Repos: []string{"myrepo"}, Expect: ListAllow},
+		{Tool: "Bash", Input: map[string]any{"command": "git status && rm -rf x"}, Expect: ListAllow},
+	}
+
+	var out bytes.Buffer
+	err := RunTests(tiersDir, cases, &out)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4") {
+		t.Errorf("expected 1 of 4 cases to fail, got %v", err)
+	}
+
+	for _, line := range []string{
+		"ok 1 - Bash(git status) # allow by Bash(git *) (global tier)",
+		"ok 2 - push asks # ask by Bash(git push:*) (global tier)",
+		"ok 3 - Bash(make lint) # allow by Bash(make:*) (myrepo tier)",
+		"not ok 4 - Bash(git status && rm -rf x)",
+		"  got: none",
+		"    rm -rf x: no allow rule matches",
+		"1..4",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %q in output:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	ev := Evaluation{
+		Permission: "Bash(git status | wc -l)",
+		Repos:      []string{"myrepo"},
+		Decision: Decision{Parts: []PartMatch{
+			{Command: "git status", Rule: "Bash(git *)", Tier: "global"},
+			{Command: "wc -l"},
+		}},
+	}
+
+	var out bytes.Buffer
+	Explain(&out, ev)
+
+	want := `permission: Bash(git status | wc -l)
+tiers:      myrepo, global
+parts:
+  git status: Bash(git *) (global tier)
+  wc -l: no allow rule matches
+decision:   none: no rule matches
+`
+	if out.String() != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRunTestsFailsOnCorruptTier(t *testing.T) {
+	tiersDir := t.TempDir()
+	globalPath := filepath.Join(tiersDir, "global.json")
+	os.WriteFile(globalPath, []byte("{not json"), 0o644)
+
+	cases := []TestCase{{Tool: "Bash", Input: map[string]any{"command": "ls"}, Repos: []string{}, Expect: DecisionNone}}
+	var out bytes.Buffer
+	if err := RunTests(tiersDir, cases, &out); err == nil {
+		t.Fatalf("expected a corrupt tier to fail the run, got:\n%s", out.String())
+	}
+
+	if _, err := Evaluate(tiersDir, Request{ToolName: "Read", CWD: "/"}, []string{}); !errors.Is(err, ErrCorruptTier) {
+		t.Errorf("expected ErrCorruptTier, got %v", err)
+	}
+	if data, _ := os.ReadFile(globalPath); string(data) != "{not json" {
+		t.Errorf("expected the corrupt tier to be left in place")
+	}
+	if backups, _ := filepath.Glob(globalPath + ".corrupt-*"); len(backups) != 0 {
+		t.Errorf("expected no backup, found %v", backups)
+	}
+}
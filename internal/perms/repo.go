@@ -0,0 +1,82 @@
+package perms
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/git"
+)
+
+// RepoTierNames returns the names of the repo tiers that apply to sessions
+// in the repo at repoPath, most specific first: the normalized URL of its
+// origin remote, so that every clone of a project shares a tier, then the
+// repo's directory name.
+func RepoTierNames(repoPath string) []string {
+	var names []string
+	if remote, err := git.Run(repoPath, "remote", "get-url", "origin"); err == nil {
+		if key := NormalizeRemoteURL(remote); key != "" {
+			names = append(names, key)
+		}
+	}
+	return append(names, filepath.Base(repoPath))
+}
+
+// repoTierNamesFromCWD resolves the repo tiers for a hook's working
+// directory. vars.Repo comes from git, which also understands sweatshop's
+// <repo>/.worktrees/<branch> layout; path conventions are only a fallback
+// for directories git cannot resolve.
+func repoTierNamesFromCWD(cwd string, vars PathVars) []string {
+	if vars.Repo != "" {
+		return RepoTierNames(vars.Repo)
+	}
+	if repo := repoFromCWD(cwd); repo != "" {
+		return []string{repo}
+	}
+	return nil
+}
+
+// NormalizeRemoteURL turns a git remote URL into a tier name of the form
+// host/path, so that https://github.com/owner/name.git and
+// git@github.com:owner/name both become github.com/owner/name. Local paths
+// and file URLs yield "", as they do not identify a project, and so do URLs
+// with . or .. segments, which would make the tier name a path outside the
+// repo tiers.
+func NormalizeRemoteURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+
+	var host, path string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "file" {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		colon := strings.Index(raw, ":")
+		if colon < 0 || strings.Contains(raw[:colon], "/") {
+			return ""
+		}
+		host, path = raw[:colon], raw[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" || host == "." || host == ".." {
+		return ""
+	}
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "":
+			continue
+		case ".", "..":
+			return ""
+		}
+		segments = append(segments, segment)
+	}
+	return strings.ToLower(host) + "/" + strings.Join(segments, "/")
+}
@@ -1,9 +1,5 @@
 package perms
 
-import (
-	"path/filepath"
-)
-
 const (
 	ReviewPromoteGlobal = "global"
 	ReviewPromoteRepo   = "repo"
@@ -14,45 +10,68 @@ const (
 type ReviewDecision struct {
 	Rule   string
 	Action string
+	// List is the permission list the rule came from and is promoted to;
+	// empty means ListAllow.
+	List string
+	// Covers are the settings rules a promoted Rule makes redundant, which
+	// are removed from settings in its place. Nil means Rule itself.
+	Covers []string
+	// Info is recorded with the rule in the tier it is promoted to.
+	Info RuleInfo
+}
+
+func (d ReviewDecision) removes() []string {
+	if d.Covers != nil {
+		return d.Covers
+	}
+	return []string{d.Rule}
+}
+
+func (d ReviewDecision) list() string {
+	if d.List == "" {
+		return ListAllow
+	}
+	return d.List
 }
 
 func RouteDecisions(tiersDir, repo, settingsPath string, decisions []ReviewDecision) error {
-	var toRemove []string
+	toRemove := map[string][]string{}
 
 	for _, d := range decisions {
+		list := d.list()
+
 		switch d.Action {
 		case ReviewPromoteGlobal:
-			globalPath := filepath.Join(tiersDir, "global.json")
-			if err := AppendToTierFile(globalPath, d.Rule); err != nil {
+			if _, err := AddRuleWithInfo(TierPath(tiersDir, "global"), list, d.Rule, d.Info); err != nil {
 				return err
 			}
-			toRemove = append(toRemove, d.Rule)
+			toRemove[list] = append(toRemove[list], d.removes()...)
 
 		case ReviewPromoteRepo:
-			repoPath := filepath.Join(tiersDir, "repos", repo+".json")
-			if err := AppendToTierFile(repoPath, d.Rule); err != nil {
+			if _, err := AddRuleWithInfo(TierPath(tiersDir, repo), list, d.Rule, d.Info); err != nil {
 				return err
 			}
-			toRemove = append(toRemove, d.Rule)
+			toRemove[list] = append(toRemove[list], d.removes()...)
 
 		case ReviewDiscard:
-			toRemove = append(toRemove, d.Rule)
+			toRemove[list] = append(toRemove[list], d.removes()...)
 
 		case ReviewKeep:
 			// Leave in settings, nothing to do.
 		}
 	}
 
-	if len(toRemove) == 0 {
+	if len(toRemove) == 0 || settingsPath == "" {
 		return nil
 	}
 
-	current, err := LoadClaudeSettings(settingsPath)
-	if err != nil {
-		return err
-	}
-
-	remaining := RemoveRules(current, toRemove)
-
-	return SaveClaudeSettings(settingsPath, remaining)
+	return updateClaudePermissions(settingsPath, func(current Tier) map[string][]string {
+		remaining := map[string][]string{}
+		for _, list := range Lists {
+			if len(toRemove[list]) > 0 {
+				remaining[list] = RemoveRules(*current.List(list), toRemove[list])
+			}
+		}
+		return remaining
+	})
 }
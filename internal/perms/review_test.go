@@ -149,3 +149,60 @@ func TestRouteDecisionsKeep(t *testing.T) {
 		t.Errorf("expected Edit, got %q", remaining[1])
 	}
 }
+
+func TestRouteDecisionsDenyList(t *testing.T) {
+	tmpDir := t.TempDir()
+	tiersDir := filepath.Join(tmpDir, "tiers")
+
+	settingsPath := filepath.Join(tmpDir, ".claude", "settings.local.json")
+	SaveClaudeSettings(settingsPath, []string{"Read"})
+	SaveClaudePermissionList(settingsPath, ListDeny, []string{"Bash(rm -rf:*)"})
+
+	decisions := []ReviewDecision{
+		{Rule: "Bash(rm -rf:*)", Action: ReviewPromoteGlobal, List: ListDeny},
+	}
+
+	if err := RouteDecisions(tiersDir, "myrepo", settingsPath, decisions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	global, _ := LoadTierFile(TierPath(tiersDir, "global"))
+	if len(global.Deny) != 1 || len(global.Allow) != 0 {
+		t.Errorf("expected rule promoted to the global deny list, got %+v", global)
+	}
+
+	remaining, err := LoadClaudePermissions(settingsPath)
+	if err != nil {
+		t.Fatalf("failed to load settings: %v", err)
+	}
+	if len(remaining.Deny) != 0 {
+		t.Errorf("expected deny rule removed from settings, got %v", remaining.Deny)
+	}
+	if len(remaining.Allow) != 1 {
+		t.Errorf("expected allow list untouched, got %v", remaining.Allow)
+	}
+}
+
+func TestRouteDecisionsRecordsInfoInNewTier(t *testing.T) {
+	tmpDir := t.TempDir()
+	tiersDir := filepath.Join(tmpDir, "tiers")
+
+	settingsPath := filepath.Join(tmpDir, ".claude", "settings.local.json")
+	SaveClaudeSettings(settingsPath, []string{"Bash(go test:*)"})
+
+	info := RuleInfo{Reason: "reviewed", Source: "/src/proj"}
+	decisions := []ReviewDecision{
+		{Rule: "Bash(go test:*)", Action: ReviewPromoteRepo, Info: info},
+	}
+	if err := RouteDecisions(tiersDir, "proj", settingsPath, decisions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tier, err := LoadTierFile(TierPath(tiersDir, "proj"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := tier.Info(ListAllow, "Bash(go test:*)"); got != info {
+		t.Errorf("expected the rule's provenance recorded, got %+v", got)
+	}
+}
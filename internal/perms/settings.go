@@ -5,65 +5,109 @@ import (
 	"errors"
 	"io/fs"
 	"os"
-	"path/filepath"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
 )
 
 type claudeSettings struct {
-	Permissions struct {
-		Allow []string `json:"allow"`
-	} `json:"permissions"`
+	Permissions Tier `json:"permissions"`
 }
 
 // LoadClaudeSettings reads the allow list from a Claude settings.local.json
 // file. Returns nil and no error when the file does not exist.
 func LoadClaudeSettings(path string) ([]string, error) {
+	permissions, err := LoadClaudePermissions(path)
+	return permissions.Allow, err
+}
+
+// LoadClaudePermissions reads the allow, ask and deny lists from a Claude
+// settings.local.json file. Returns empty lists and no error when the file
+// does not exist.
+func LoadClaudePermissions(path string) (Tier, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return nil, nil
+			return Tier{}, nil
 		}
-		return nil, err
+		return Tier{}, err
 	}
 
 	var settings claudeSettings
 	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, err
+		return Tier{}, err
 	}
 
-	return settings.Permissions.Allow, nil
+	return settings.Permissions, nil
 }
 
 // SaveClaudeSettings writes the allow list back to a Claude settings.local.json
 // file, preserving any other top-level keys. Creates parent directories as needed.
 func SaveClaudeSettings(path string, rules []string) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
+	return SaveClaudePermissionList(path, ListAllow, rules)
+}
 
-	// Read existing file to preserve non-permission fields
-	var doc map[string]any
-	if existing, err := os.ReadFile(path); err == nil {
-		json.Unmarshal(existing, &doc)
-	}
-	if doc == nil {
-		doc = make(map[string]any)
-	}
+// SaveClaudePermissionList writes one permission list back to a Claude
+// settings.local.json file, preserving the other lists and top-level keys.
+func SaveClaudePermissionList(path, list string, rules []string) error {
+	return updateClaudePermissions(path, func(Tier) map[string][]string {
+		return map[string][]string{list: rules}
+	})
+}
 
-	permsMap, _ := doc["permissions"].(map[string]any)
-	if permsMap == nil {
-		permsMap = make(map[string]any)
-	}
-	permsMap["allow"] = rules
-	doc["permissions"] = permsMap
+// updateClaudePermissions rewrites the permission lists fn returns, given
+// the current ones, in a Claude settings.local.json file. It holds the
+// file's lock while reading and writes atomically, so that concurrent
+// reviews do not lose each other's changes or leave a torn file for Claude.
+func updateClaudePermissions(path string, fn func(current Tier) map[string][]string) error {
+	return filelock.With(path, func() error {
+		// Read existing file to preserve non-permission fields
+		var doc map[string]any
+		var current claudeSettings
+		if existing, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(existing, &doc)
+			json.Unmarshal(existing, &current)
+		}
+		if doc == nil {
+			doc = make(map[string]any)
+		}
 
-	data, err := json.MarshalIndent(doc, "", "  ")
-	if err != nil {
-		return err
-	}
+		permsMap, _ := doc["permissions"].(map[string]any)
+		if permsMap == nil {
+			permsMap = make(map[string]any)
+		}
+		for list, rules := range fn(current.Permissions) {
+			permsMap[list] = rules
+		}
+		doc["permissions"] = permsMap
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
 
-	data = append(data, '\n')
+		data = append(data, '\n')
 
-	return os.WriteFile(path, data, 0o644)
+		return filelock.WriteFile(path, data, 0o644)
+	})
+}
+
+// RelocateClaudeSettings rewrites the worktree paths in a Claude
+// settings.local.json file after the worktree moved from fromPath to toPath.
+// Like updateClaudePermissions, it holds the file's lock and writes
+// atomically. A missing file is left alone.
+func RelocateClaudeSettings(path, fromPath, toPath string) error {
+	return filelock.With(path, func() error {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data = []byte(strings.ReplaceAll(string(data), "//"+fromPath+"/", "//"+toPath+"/"))
+		return filelock.WriteFile(path, data, 0o644)
+	})
 }
 
 // DiffRules returns rules present in after but not in before, preserving the
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -83,6 +84,30 @@ func TestSaveClaudeSettings(t *testing.T) {
 	}
 }
 
+func TestRelocateClaudeSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.local.json")
+	from, to := "/wt/fix-2", "/wt/fix"
+	// Worktree rules are written as //<absolute path>, like sweatfile does.
+	rules := []string{"Edit(//" + from + "/**)", "Read(//" + from + "0/**)"}
+	if err := SaveClaudeSettings(path, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RelocateClaudeSettings(path, from, to); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := LoadClaudeSettings(path)
+	want := []string{"Edit(//" + to + "/**)", rules[1]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := RelocateClaudeSettings(filepath.Join(t.TempDir(), "missing.json"), "/a", "/b"); err != nil {
+		t.Errorf("expected a missing file to be left alone, got %v", err)
+	}
+}
+
 func TestDiffRules(t *testing.T) {
 	before := []string{"Read", "Edit"}
 	after := []string{"Read", "Edit", "Bash(go test:*)", "Write"}
@@ -0,0 +1,699 @@
+package perms
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shellKeywords are reserved words that may lead a simple command without
+// being the program it runs, e.g. "then" in "if true; then rm x; fi".
+var shellKeywords = map[string]bool{
+	"!": true, "{": true, "}": true, "time": true,
+	"if": true, "then": true, "elif": true, "else": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true,
+}
+
+// SplitCommand breaks a shell command line into the simple commands it runs,
+// so that each can be checked on its own. It splits on &&, ||, ;, &, pipes
+// and newlines, and descends into subshells, { } groups, command and process
+// substitutions, including those inside double quotes. Words keep their
+// quoting; dequoteCommand removes it from a part for matching ask and deny
+// rules.
+//
+// Redirections are removed from the command they belong to. Output
+// redirected anywhere other than /dev/null or another file descriptor is a
+// write that no command rule vouches for, so it is returned as a part of its
+// own, such as "> out.txt".
+//
+// Constructs it cannot follow, such as heredocs or unbalanced quotes, are an
+// error; callers should then not auto-approve the command.
+func SplitCommand(command string) ([]string, error) {
+	p := &shellParser{src: command}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.parts, nil
+}
+
+type shellParser struct {
+	src string
+	pos int
+
+	parts []string
+	words []string
+	word  strings.Builder
+	// inWord is set once the current word has any text, which may be an
+	// empty quoted string.
+	inWord bool
+	// redirect is the redirection operator waiting for its target word.
+	redirect string
+}
+
+func (p *shellParser) parse() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+
+		switch {
+		case c == ' ' || c == '\t':
+			p.endWord()
+			p.pos++
+
+		case c == '\n':
+			if err := p.endCommand(); err != nil {
+				return err
+			}
+			p.pos++
+
+		case c == '#' && !p.inWord:
+			if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+				p.pos += end
+			} else {
+				p.pos = len(p.src)
+			}
+
+		case c == '\\':
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n' {
+				p.pos += 2
+				continue
+			}
+			end := min(p.pos+2, len(p.src))
+			p.appendRaw(p.src[p.pos:end])
+			p.pos = end
+
+		case c == '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return fmt.Errorf("unterminated single quote")
+			}
+			end += p.pos + 1
+			p.appendRaw(p.src[p.pos : end+1])
+			p.pos = end + 1
+
+		case c == '"':
+			if err := p.doubleQuoted(); err != nil {
+				return err
+			}
+
+		case c == '`':
+			if err := p.backquoted(); err != nil {
+				return err
+			}
+
+		case c == '$':
+			if err := p.dollar(); err != nil {
+				return err
+			}
+
+		case c == '(':
+			if p.inWord || len(p.words) > 0 {
+				return fmt.Errorf("unsupported ( after %q", p.current())
+			}
+			end, err := p.matchingParen(p.pos)
+			if err != nil {
+				return err
+			}
+			if err := p.split(p.src[p.pos+1 : end]); err != nil {
+				return err
+			}
+			p.pos = end + 1
+
+		case c == ')':
+			return fmt.Errorf("unbalanced )")
+
+		case c == '<' || c == '>':
+			if err := p.redirection(); err != nil {
+				return err
+			}
+
+		case c == '&':
+			if strings.HasPrefix(p.src[p.pos:], "&>") {
+				p.endWord()
+				if err := p.startRedirect(p.operator("&>>", "&>")); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := p.endCommand(); err != nil {
+				return err
+			}
+			p.pos += len(p.operator("&&", "&"))
+
+		case c == '|':
+			if err := p.endCommand(); err != nil {
+				return err
+			}
+			p.pos += len(p.operator("||", "|&", "|"))
+
+		case c == ';':
+			if err := p.endCommand(); err != nil {
+				return err
+			}
+			p.pos += len(p.operator(";;", ";"))
+
+		default:
+			p.appendRaw(p.src[p.pos : p.pos+1])
+			p.pos++
+		}
+	}
+
+	return p.endCommand()
+}
+
+// operator returns the first of ops found at the current position.
+func (p *shellParser) operator(ops ...string) string {
+	for _, op := range ops {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *shellParser) appendRaw(s string) {
+	p.word.WriteString(s)
+	p.inWord = true
+}
+
+func (p *shellParser) current() string {
+	return strings.TrimSpace(strings.Join(p.words, " ") + " " + p.word.String())
+}
+
+func (p *shellParser) endWord() {
+	if !p.inWord {
+		return
+	}
+
+	word := p.word.String()
+	p.word.Reset()
+	p.inWord = false
+
+	if p.redirect != "" {
+		p.addRedirect(p.redirect, word)
+		p.redirect = ""
+		return
+	}
+
+	if len(p.words) == 0 && shellKeywords[word] {
+		return
+	}
+
+	p.words = append(p.words, word)
+}
+
+func (p *shellParser) endCommand() error {
+	p.endWord()
+	if p.redirect != "" {
+		return fmt.Errorf("missing target for %s", p.redirect)
+	}
+
+	if len(p.words) > 0 {
+		p.parts = append(p.parts, strings.Join(p.words, " "))
+	}
+	p.words = nil
+	return nil
+}
+
+// redirection handles an operator starting with < or >, including a file
+// descriptor number written right before it and process substitutions.
+func (p *shellParser) redirection() error {
+	if strings.HasPrefix(p.src[p.pos:], "<(") || strings.HasPrefix(p.src[p.pos:], ">(") {
+		return p.substitution(p.pos, p.pos+1)
+	}
+
+	fd := ""
+	if p.inWord && isDigits(p.word.String()) {
+		fd = p.word.String()
+		p.word.Reset()
+		p.inWord = false
+	} else {
+		p.endWord()
+	}
+
+	op := p.operator("<<<", "<<", "<>", "<&", "<", ">>", ">|", ">&", ">")
+	if op == "<<" {
+		return fmt.Errorf("heredocs are not supported")
+	}
+	return p.startRedirect(fd + op)
+}
+
+func (p *shellParser) startRedirect(op string) error {
+	if p.redirect != "" {
+		return fmt.Errorf("missing target for %s", p.redirect)
+	}
+	p.redirect = op
+	p.pos += len(strings.TrimLeft(op, "0123456789"))
+	return nil
+}
+
+// addRedirect records the redirection of op to target when it writes to a
+// file.
+func (p *shellParser) addRedirect(op, target string) {
+	bare := strings.TrimLeft(op, "0123456789")
+	if !strings.Contains(bare, ">") {
+		return
+	}
+	if (bare == ">&" || bare == "<&") && (isDigits(target) || target == "-") {
+		return
+	}
+	if target == "/dev/null" {
+		return
+	}
+	p.parts = append(p.parts, op+" "+target)
+}
+
+func (p *shellParser) doubleQuoted() error {
+	start := p.pos
+	p.pos++
+
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\\':
+			p.pos += 2
+
+		case c == '"':
+			p.pos++
+			p.appendRaw(p.src[start:p.pos])
+			return nil
+
+		case c == '`':
+			end, err := p.closingBackquote(p.pos)
+			if err != nil {
+				return err
+			}
+			if err := p.split(p.src[p.pos+1 : end]); err != nil {
+				return err
+			}
+			p.pos = end + 1
+
+		case c == '$' && strings.HasPrefix(p.src[p.pos:], "$(("):
+			end, err := p.arithmetic(p.pos)
+			if err != nil {
+				return err
+			}
+			p.pos = end + 1
+
+		case c == '$' && strings.HasPrefix(p.src[p.pos:], "$("):
+			end, err := p.matchingParen(p.pos + 1)
+			if err != nil {
+				return err
+			}
+			if err := p.split(p.src[p.pos+2 : end]); err != nil {
+				return err
+			}
+			p.pos = end + 1
+
+		default:
+			p.pos++
+		}
+	}
+
+	return fmt.Errorf("unterminated double quote")
+}
+
+func (p *shellParser) backquoted() error {
+	end, err := p.closingBackquote(p.pos)
+	if err != nil {
+		return err
+	}
+	if err := p.split(p.src[p.pos+1 : end]); err != nil {
+		return err
+	}
+	p.appendRaw(p.src[p.pos : end+1])
+	p.pos = end + 1
+	return nil
+}
+
+func (p *shellParser) closingBackquote(open int) (int, error) {
+	for i := open + 1; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '`':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated backquote")
+}
+
+// dollar handles $(...) command substitution, $((...)) arithmetic and
+// $'...' quoting; other expansions are ordinary word text.
+func (p *shellParser) dollar() error {
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "$'"):
+		end, err := closingANSIQuote(p.src, p.pos+1)
+		if err != nil {
+			return err
+		}
+		p.appendRaw(p.src[p.pos : end+1])
+		p.pos = end + 1
+		return nil
+
+	case strings.HasPrefix(p.src[p.pos:], "$(("):
+		end, err := p.arithmetic(p.pos)
+		if err != nil {
+			return err
+		}
+		p.appendRaw(p.src[p.pos : end+1])
+		p.pos = end + 1
+		return nil
+
+	case strings.HasPrefix(p.src[p.pos:], "$("):
+		return p.substitution(p.pos, p.pos+1)
+
+	default:
+		p.appendRaw("$")
+		p.pos++
+		return nil
+	}
+}
+
+// arithmetic returns the index of the ) closing the $(( at start, after
+// splitting the command substitutions inside it, which the shell runs
+// before evaluating the expression.
+func (p *shellParser) arithmetic(start int) (int, error) {
+	end, err := p.matchingParen(start + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := start + 3; i < end; i++ {
+		switch {
+		case p.src[i] == '\\':
+			i++
+
+		case p.src[i] == '`':
+			close, err := p.closingBackquote(i)
+			if err != nil {
+				return 0, err
+			}
+			if err := p.split(p.src[i+1 : close]); err != nil {
+				return 0, err
+			}
+			i = close
+
+		case strings.HasPrefix(p.src[i:], "$(") && !strings.HasPrefix(p.src[i:], "$(("):
+			close, err := p.matchingParen(i + 1)
+			if err != nil {
+				return 0, err
+			}
+			if err := p.split(p.src[i+2 : close]); err != nil {
+				return 0, err
+			}
+			i = close
+		}
+	}
+
+	return end, nil
+}
+
+// substitution splits the commands inside the parentheses opening at open,
+// and appends the whole construct from start as word text.
+func (p *shellParser) substitution(start, open int) error {
+	end, err := p.matchingParen(open)
+	if err != nil {
+		return err
+	}
+	if err := p.split(p.src[open+1 : end]); err != nil {
+		return err
+	}
+	p.appendRaw(p.src[start : end+1])
+	p.pos = end + 1
+	return nil
+}
+
+func (p *shellParser) split(inner string) error {
+	parts, err := SplitCommand(inner)
+	if err != nil {
+		return err
+	}
+	p.parts = append(p.parts, parts...)
+	return nil
+}
+
+// matchingParen returns the index of the ) closing the ( at open, skipping
+// over quoted text.
+func (p *shellParser) matchingParen(open int) (int, error) {
+	depth := 0
+	for i := open; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(p.src[i+1:], '\'')
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated single quote")
+			}
+			i += end + 1
+		case '"':
+			for i++; i < len(p.src) && p.src[i] != '"'; i++ {
+				if p.src[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced (")
+}
+
+// closingANSIQuote returns the index of the ' closing the $'...' string whose
+// opening quote is at open. Unlike in '...', a backslash escapes a quote.
+func closingANSIQuote(src string, open int) (int, error) {
+	for i := open + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '\'':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated $' quote")
+}
+
+// dequoteCommand returns a part of SplitCommand's result as the words the
+// program receives: quotes and backslash escapes removed and $'...' strings
+// decoded, so that git push '--force' reads as git push --force. Expansions
+// and substitutions are kept as written. Words are joined with single
+// spaces. It is an error when the quoting cannot be undone, in which case
+// the part must not be auto-approved.
+func dequoteCommand(part string) (string, error) {
+	p := &shellParser{src: part}
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for p.pos < len(part) {
+		c := part[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			p.pos++
+			continue
+
+		case c == '\\':
+			if p.pos+1 == len(part) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			word.WriteByte(part[p.pos+1])
+			p.pos += 2
+
+		case c == '\'':
+			end := strings.IndexByte(part[p.pos+1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(part[p.pos+1 : p.pos+1+end])
+			p.pos += end + 2
+
+		case c == '"' || strings.HasPrefix(part[p.pos:], "$\""):
+			if c == '$' {
+				p.pos++
+			}
+			if err := p.dequoteDouble(&word); err != nil {
+				return "", err
+			}
+
+		case strings.HasPrefix(part[p.pos:], "$'"):
+			end, err := closingANSIQuote(part, p.pos+1)
+			if err != nil {
+				return "", err
+			}
+			decoded, err := decodeANSIQuoted(part[p.pos+2 : end])
+			if err != nil {
+				return "", err
+			}
+			word.WriteString(decoded)
+			p.pos = end + 1
+
+		case strings.HasPrefix(part[p.pos:], "$("):
+			end, err := p.matchingParen(p.pos + 1)
+			if err != nil {
+				return "", err
+			}
+			word.WriteString(part[p.pos : end+1])
+			p.pos = end + 1
+
+		case c == '`':
+			end, err := p.closingBackquote(p.pos)
+			if err != nil {
+				return "", err
+			}
+			word.WriteString(part[p.pos : end+1])
+			p.pos = end + 1
+
+		default:
+			word.WriteByte(c)
+			p.pos++
+		}
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// dequoteDouble writes the text of the "..." string at the current position
+// to word, without its quotes and escapes, and moves past it.
+func (p *shellParser) dequoteDouble(word *strings.Builder) error {
+	for p.pos++; p.pos < len(p.src); {
+		switch c := p.src[p.pos]; {
+		case c == '"':
+			p.pos++
+			return nil
+
+		case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte("$`\"\\\n", p.src[p.pos+1]) >= 0:
+			if p.src[p.pos+1] != '\n' {
+				word.WriteByte(p.src[p.pos+1])
+			}
+			p.pos += 2
+
+		case strings.HasPrefix(p.src[p.pos:], "$("):
+			end, err := p.matchingParen(p.pos + 1)
+			if err != nil {
+				return err
+			}
+			word.WriteString(p.src[p.pos : end+1])
+			p.pos = end + 1
+
+		case c == '`':
+			end, err := p.closingBackquote(p.pos)
+			if err != nil {
+				return err
+			}
+			word.WriteString(p.src[p.pos : end+1])
+			p.pos = end + 1
+
+		default:
+			word.WriteByte(c)
+			p.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote")
+}
+
+// ansiEscapes are the single-character escapes of $'...' strings.
+var ansiEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n', 'r': '\r',
+	't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// decodeANSIQuoted decodes the body of a $'...' string the way bash does. A
+// NUL, which ends the string in bash, is an error, as is an escape bash
+// would keep but a rule author would not expect, such as \q.
+func decodeANSIQuoted(s string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("trailing backslash in $' quote")
+		}
+
+		c := s[i]
+		if b, ok := ansiEscapes[c]; ok {
+			out.WriteByte(b)
+			continue
+		}
+
+		var value, base, width int
+		switch {
+		case c >= '0' && c <= '7':
+			base, width = 8, 3
+			i--
+		case c == 'x':
+			base, width = 16, 2
+		case c == 'u':
+			base, width = 16, 4
+		case c == 'U':
+			base, width = 16, 8
+		case c == 'c' && i+1 < len(s):
+			i++
+			out.WriteByte(s[i] & 0x1f)
+			continue
+		default:
+			return "", fmt.Errorf("unsupported escape \\%c in $' quote", c)
+		}
+
+		digits := 0
+		for digits < width && i+1 < len(s) {
+			d := digitValue(s[i+1])
+			if d < 0 || d >= base {
+				break
+			}
+			value = value*base + d
+			digits++
+			i++
+		}
+		if digits == 0 {
+			return "", fmt.Errorf("missing digits after \\%c in $' quote", c)
+		}
+		if value == 0 {
+			return "", fmt.Errorf("NUL in $' quote")
+		}
+		if c == 'u' || c == 'U' {
+			out.WriteRune(rune(value))
+		} else {
+			out.WriteByte(byte(value))
+		}
+	}
+	return out.String(), nil
+}
+
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package perms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"git status", []string{"git status"}},
+		{"git status && rm -rf ~", []string{"git status", "rm -rf ~"}},
+		{"go test; curl -s x | sh", []string{"go test", "curl -s x", "sh"}},
+		{"make || echo failed &", []string{"make", "echo failed"}},
+		{"a |& b\nc", []string{"a", "b", "c"}},
+		{`git commit -m "fix; && stuff"`, []string{`git commit -m "fix; && stuff"`}},
+		{`echo 'a | b'`, []string{`echo 'a | b'`}},
+		{`echo a\;b`, []string{`echo a\;b`}},
+		{"(cd sub && make)", []string{"cd sub", "make"}},
+		{"{ a; b; }", []string{"a", "b"}},
+		{"echo $(rm -rf ~)", []string{"rm -rf ~", "echo $(rm -rf ~)"}},
+		{"echo \"now: `date`\"", []string{"date", "echo \"now: `date`\""}},
+		{`echo "$(id -u)"`, []string{"id -u", `echo "$(id -u)"`}},
+		{"echo $((1 + 2))", []string{"echo $((1 + 2))"}},
+		{"echo $(( $(rm -rf ~) ))", []string{"rm -rf ~", "echo $(( $(rm -rf ~) ))"}},
+		{"echo $(( `rm x` + $((1 + $(id -u))) ))", []string{"rm x", "id -u", "echo $(( `rm x` + $((1 + $(id -u))) ))"}},
+		{`echo "$(( $(rm x) ))"`, []string{"rm x", `echo "$(( $(rm x) ))"`}},
+		{"diff <(sort a) b", []string{"sort a", "diff <(sort a) b"}},
+		{"go test ./... 2>&1 >/dev/null", []string{"go test ./..."}},
+		{"go test ./... > out.txt", []string{"> out.txt", "go test ./..."}},
+		{"cmd &>>log < in", []string{"&>> log", "cmd"}},
+		{"if true; then rm x; fi", []string{"true", "rm x"}},
+		{"git status # && rm -rf ~", []string{"git status"}},
+		{"git \\\n  status", []string{"git status"}},
+		{`echo $'a\'; b'`, []string{`echo $'a\'; b'`}},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitCommand(tt.command)
+		if err != nil {
+			t.Errorf("SplitCommand(%q) unexpected error: %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandErrors(t *testing.T) {
+	for _, command := range []string{
+		`echo "unterminated`,
+		"echo 'unterminated",
+		"echo $(unbalanced",
+		"echo )",
+		"cat <<EOF\nhi\nEOF",
+		"echo >",
+		"f() { rm x; }",
+		"echo $(( $(rm x ))",
+		`echo "$(( $(rm x ) )"`,
+	} {
+		if _, err := SplitCommand(command); err == nil {
+			t.Errorf("SplitCommand(%q) expected error", command)
+		}
+	}
+}
+
+func TestDequoteCommand(t *testing.T) {
+	tests := []struct {
+		part string
+		want string
+	}{
+		{"git push --force", "git push --force"},
+		{"git push '--force' origin", "git push --force origin"},
+		{`git push "--force" origin`, "git push --force origin"},
+		{`git push \-\-force`, "git push --force"},
+		{`git push --f"or"'ce'`, "git push --force"},
+		{`git push $'\x2d\x2dforce'`, "git push --force"},
+		{`git push $'\055-force'`, "git push --force"},
+		{`git push $"--force"`, "git push --force"},
+		{`r\m -rf ~`, "rm -rf ~"},
+		{`git commit -m "a \"b\" \$c"`, `git commit -m a "b" $c`},
+		{`echo "$(printf '%s' x)" $HOME`, `echo $(printf '%s' x) $HOME`},
+	}
+
+	for _, tt := range tests {
+		got, err := dequoteCommand(tt.part)
+		if err != nil {
+			t.Errorf("dequoteCommand(%q) unexpected error: %v", tt.part, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("dequoteCommand(%q) = %q, want %q", tt.part, got, tt.want)
+		}
+	}
+
+	for _, part := range []string{`echo $'\q'`, `echo $'a\0b'`, `echo $'\x'`} {
+		if _, err := dequoteCommand(part); err == nil {
+			t.Errorf("dequoteCommand(%q) expected error", part)
+		}
+	}
+}
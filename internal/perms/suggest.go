@@ -0,0 +1,418 @@
+package perms
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// Observation is one sighting of a rule a user approved by hand: an allow
+// rule saved in a worktree's settings.local.json, or a request no tier
+// decided that the user then approved, taken from the audit log.
+type Observation struct {
+	Rule string
+	// Repo is the repo tier name of the repo it was seen in.
+	Repo string
+	// Worktree is the checkout it was seen in, if known.
+	Worktree string
+	// SettingsPath is the settings file holding the rule; empty for
+	// requests from the audit log.
+	SettingsPath string
+}
+
+// SettingsObservations returns the allow rules saved in settings.local.json
+// by the sessions of every worktree of the repos found from startDir. Rules
+// sweatshop wrote itself when creating the worktree are left out.
+func SettingsObservations(startDir, home string) []Observation {
+	var obs []Observation
+	for _, repoPath := range worktree.ScanRepos(startDir) {
+		repo := RepoTierNames(repoPath)[0]
+
+		var sweatfileRules []string
+		if result, err := sweatfile.LoadHierarchy(home, repoPath); err == nil {
+			sweatfileRules = result.Merged.ClaudeAllow
+		}
+
+		for _, wtPath := range worktree.ListWorktrees(repoPath) {
+			settingsPath := filepath.Join(wtPath, ".claude", "settings.local.json")
+			perms, err := LoadClaudePermissions(settingsPath)
+			if err != nil {
+				continue
+			}
+
+			managed := sweatfile.ManagedClaudeRules(wtPath, sweatfileRules)
+			for _, rule := range perms.Allow {
+				if contains(managed, rule) {
+					continue
+				}
+				obs = append(obs, Observation{
+					Rule:         rule,
+					Repo:         repo,
+					Worktree:     wtPath,
+					SettingsPath: settingsPath,
+				})
+			}
+		}
+	}
+	return obs
+}
+
+// AuditObservations returns the requests in records that no rule decided
+// and that the user then approved in Claude's own prompt, as shown by an
+// allow rule covering the request in the settings of the same worktree among
+// approved. Prompts that left no such rule may have been denied, so they do
+// not count.
+func AuditObservations(records []AuditRecord, approved []Observation) []Observation {
+	approvedRules := map[string][]string{}
+	for _, o := range approved {
+		if o.Worktree != "" {
+			approvedRules[o.Worktree] = append(approvedRules[o.Worktree], o.Rule)
+		}
+	}
+
+	worktrees := map[string]string{}
+
+	var obs []Observation
+	for _, rec := range records {
+		if rec.Decision != DecisionNone || rec.Permission == "" {
+			continue
+		}
+
+		wt, ok := worktrees[rec.CWD]
+		if !ok {
+			wt = ResolvePathVars(rec.CWD).Worktree
+			worktrees[rec.CWD] = wt
+		}
+		if !approvedIn(approvedRules[wt], rec.Permission) {
+			continue
+		}
+
+		repo := rec.Remote
+		if repo == "" {
+			repo = rec.Repo
+		}
+		obs = append(obs, Observation{Rule: rec.Permission, Repo: repo, Worktree: wt})
+	}
+	return obs
+}
+
+// approvedIn reports whether rules allow permission. A Bash command is
+// allowed when each simple command in it is, as perms check would decide.
+func approvedIn(rules []string, permission string) bool {
+	covered := func(p string) bool {
+		for _, rule := range rules {
+			if rule == p || validateRule(rule) == nil && ruleCovers(rule, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	tool, command := parseRule(permission)
+	if tool != "Bash" || command == "" {
+		return covered(permission)
+	}
+	parts, err := SplitCommand(command)
+	if err != nil || len(parts) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		if !covered("Bash(" + part + ")") {
+			return false
+		}
+	}
+	return true
+}
+
+// SuggestOptions set how often a rule must have been approved to be
+// suggested. A rule qualifies by meeting either threshold.
+type SuggestOptions struct {
+	// MinWorktrees is the number of distinct worktrees whose settings hold
+	// the rule.
+	MinWorktrees int
+	// MinRequests is the number of audited requests no tier decided and the
+	// user approved.
+	MinRequests int
+}
+
+// Suggestion is a rule worth promoting to a tier.
+type Suggestion struct {
+	Rule string
+	// Repos are the repo tier names it was seen in; more than one makes it
+	// a candidate for the global tier.
+	Repos     []string
+	Worktrees int
+	Requests  int
+	// Examples are the distinct observed rules Rule generalizes, when it
+	// is not one of them itself.
+	Examples []string
+	// Covers maps each settings file the rule was seen in to the rules
+	// there that Rule makes redundant.
+	Covers map[string][]string
+}
+
+// Global reports whether the rule was seen in more than one repo.
+func (s Suggestion) Global() bool {
+	return len(s.Repos) > 1
+}
+
+type suggestionGroup struct {
+	examples  []string
+	repos     []string
+	worktrees []string
+	requests  int
+	covers    map[string][]string
+}
+
+// Suggest groups observations by the generalized rule they fall under and
+// returns the groups that meet opts, most often seen first. A group whose
+// observations are all the same rule suggests that rule as is. Rules already
+// in the tier a suggestion targets are left out.
+func Suggest(obs []Observation, tiersDir string, opts SuggestOptions) []Suggestion {
+	groups := map[string]*suggestionGroup{}
+	var keys []string
+
+	for _, o := range obs {
+		for _, rule := range observedRules(o) {
+			key := generalizeRule(rule)
+			g := groups[key]
+			if g == nil {
+				g = &suggestionGroup{covers: map[string][]string{}}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+
+			g.examples = appendUnique(g.examples, rule)
+			if o.Repo != "" {
+				g.repos = appendUnique(g.repos, o.Repo)
+			}
+			if o.SettingsPath == "" {
+				g.requests++
+				continue
+			}
+			if o.Worktree != "" {
+				g.worktrees = appendUnique(g.worktrees, o.Worktree)
+			}
+			g.covers[o.SettingsPath] = appendUnique(g.covers[o.SettingsPath], o.Rule)
+		}
+	}
+
+	global, _ := LoadTierFile(TierPath(tiersDir, "global"))
+
+	var suggestions []Suggestion
+	for _, key := range keys {
+		g := groups[key]
+		if len(g.worktrees) < opts.MinWorktrees && g.requests < opts.MinRequests {
+			continue
+		}
+
+		s := Suggestion{
+			Rule:      key,
+			Repos:     g.repos,
+			Worktrees: len(g.worktrees),
+			Requests:  g.requests,
+			Covers:    g.covers,
+		}
+		if len(g.examples) == 1 {
+			s.Rule = g.examples[0]
+		} else {
+			s.Examples = g.examples
+		}
+		sort.Strings(s.Repos)
+		sort.Strings(s.Examples)
+
+		if unsafeSuggestion(s.Rule) {
+			continue
+		}
+		if contains(global.Allow, s.Rule) {
+			continue
+		}
+		if len(s.Repos) == 1 {
+			repoTier, _ := LoadTierFile(TierPath(tiersDir, s.Repos[0]))
+			if contains(repoTier.Allow, s.Rule) {
+				continue
+			}
+		}
+
+		suggestions = append(suggestions, s)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if len(a.Repos) != len(b.Repos) {
+			return len(a.Repos) > len(b.Repos)
+		}
+		if a.Worktrees+a.Requests != b.Worktrees+b.Requests {
+			return a.Worktrees+a.Requests > b.Worktrees+b.Requests
+		}
+		return a.Rule < b.Rule
+	})
+	return suggestions
+}
+
+// observedRules returns the rules an observation stands for, with paths in
+// its worktree rewritten to $WORKTREE. An audited Bash request counts once
+// per simple command in it, since that is how rules are matched against it.
+func observedRules(o Observation) []string {
+	tool, pattern := parseRule(o.Rule)
+	if tool != "Bash" || o.SettingsPath != "" || pattern == "" {
+		return []string{relativizeRule(o.Rule, o.Worktree)}
+	}
+
+	parts, err := SplitCommand(pattern)
+	if err != nil {
+		return nil
+	}
+	var rules []string
+	for _, part := range parts {
+		// Redirections to files are parts of their own that no Bash rule
+		// can allow.
+		if first, _, _ := strings.Cut(part, " "); strings.Contains(first, ">") {
+			continue
+		}
+		rules = append(rules, "Bash("+part+")")
+	}
+	return rules
+}
+
+// relativizeRule rewrites a file rule for a path inside worktree to use the
+// $WORKTREE placeholder, so that the same approval in different worktrees is
+// the same rule.
+func relativizeRule(rule, worktree string) string {
+	tool, pattern := parseRule(rule)
+	if !fileTools[tool] || worktree == "" {
+		return rule
+	}
+
+	if !strings.HasPrefix(pattern, "/") {
+		return rule
+	}
+	p := path.Clean(pattern)
+	if p != worktree && !strings.HasPrefix(p, worktree+"/") {
+		return rule
+	}
+	return tool + "(" + WorktreeVar + p[len(worktree):] + ")"
+}
+
+// generalizeRule returns the broader rule that similar approvals share:
+//   - Bash: the program and its subcommand, e.g. "go test ./pkg/a" becomes
+//     Bash(go test:*), except for riskyPrograms
+//   - file tools: the directory, or all of $WORKTREE for paths in it
+//   - WebFetch: the URL's domain
+//
+// Other rules generalize to themselves.
+func generalizeRule(rule string) string {
+	tool, pattern := parseRule(rule)
+	if pattern == "" {
+		return rule
+	}
+
+	switch {
+	case tool == "Bash":
+		words := strings.Fields(strings.TrimSuffix(strings.TrimSuffix(pattern, ":*"), "*"))
+		if len(words) == 0 {
+			return rule
+		}
+		if riskyProgram(words[0]) {
+			return rule
+		}
+		prefix := words[0]
+		if len(words) > 1 && isSubcommand(words[1]) {
+			prefix += " " + words[1]
+		}
+		return "Bash(" + prefix + ":*)"
+
+	case fileTools[tool]:
+		if strings.HasPrefix(pattern, WorktreeVar) {
+			return tool + "(" + WorktreeVar + "/**)"
+		}
+		dir, isDir := strings.CutSuffix(pattern, "/**")
+		if !isDir {
+			dir = path.Dir(pattern)
+		}
+		if dir == "/" || dir == "//" || dir == "." {
+			return rule
+		}
+		return tool + "(" + dir + "/**)"
+
+	case tool == "WebFetch":
+		if strings.HasPrefix(pattern, domainPrefix) {
+			return rule
+		}
+		if u, err := url.Parse(pattern); err == nil && u.Hostname() != "" {
+			return "WebFetch(" + domainPrefix + strings.ToLower(u.Hostname()) + ")"
+		}
+	}
+
+	return rule
+}
+
+// riskyPrograms are programs for which a prefix rule hands over arbitrary
+// code execution or destruction, e.g. Bash(python3:*) or Bash(rm:*).
+// Approvals of them are only ever suggested as the exact command.
+var riskyPrograms = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "node": true, "deno": true, "bun": true, "ruby": true,
+	"perl": true, "php": true, "lua": true, "osascript": true,
+	"env": true, "xargs": true, "eval": true, "exec": true, "nohup": true,
+	"timeout": true, "watch": true, "sudo": true, "doas": true, "su": true,
+	"npx": true, "uvx": true, "ssh": true, "curl": true, "wget": true,
+	"rm": true, "rmdir": true, "dd": true, "mkfs": true, "shred": true,
+	"truncate": true, "chmod": true, "chown": true, "kill": true,
+	"pkill": true, "killall": true, "find": true,
+}
+
+// riskyProgram reports whether word runs one of riskyPrograms, including
+// versioned names such as python3.12 and paths such as /bin/rm.
+func riskyProgram(word string) bool {
+	return riskyPrograms[strings.TrimRight(path.Base(word), "0123456789.")]
+}
+
+// unsafeSuggestion reports whether rule should never be offered for
+// promotion: a rule perms lint reports as invalid or overbroad, or a prefix
+// rule for one of riskyPrograms.
+func unsafeSuggestion(rule string) bool {
+	if validateRule(rule) != nil || isOverbroad(rule) {
+		return true
+	}
+
+	tool, pattern := parseRule(rule)
+	if tool != "Bash" || !strings.HasSuffix(pattern, "*") {
+		return false
+	}
+	words := strings.Fields(strings.TrimSuffix(strings.TrimSuffix(pattern, ":*"), "*"))
+	return len(words) == 0 || riskyProgram(words[0])
+}
+
+// isSubcommand reports whether word looks like a subcommand such as "test"
+// or "run-script" rather than a flag, path or other argument.
+func isSubcommand(word string) bool {
+	if word == "" || !isLetter(word[0]) {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if !isLetter(c) && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
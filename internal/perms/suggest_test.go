@@ -0,0 +1,202 @@
+package perms
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGeneralizeRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"Bash(go test ./pkg/a)", "Bash(go test:*)"},
+		{"Bash(go test:*)", "Bash(go test:*)"},
+		{"Bash(ls -la)", "Bash(ls:*)"},
+		{"Bash(cat README.md)", "Bash(cat:*)"},
+		{"Bash(rm -rf build)", "Bash(rm -rf build)"},
+		{"Bash(python3 x.py)", "Bash(python3 x.py)"},
+		{"Bash(python3.12 -m venv .venv)", "Bash(python3.12 -m venv .venv)"},
+		{"Bash(bash -c 'make all')", "Bash(bash -c 'make all')"},
+		{"Bash(/bin/rm x)", "Bash(/bin/rm x)"},
+		{"Read($WORKTREE/internal/a.go)", "Read($WORKTREE/**)"},
+		{"Read(/etc/hosts)", "Read(/etc/**)"},
+		{"Read(//opt/data/**)", "Read(//opt/data/**)"},
+		{"WebFetch(https://Pkg.go.dev/fmt)", "WebFetch(domain:pkg.go.dev)"},
+		{"WebFetch(domain:pkg.go.dev)", "WebFetch(domain:pkg.go.dev)"},
+		{"mcp__github__get_issue", "mcp__github__get_issue"},
+	}
+
+	for _, tt := range tests {
+		if got := generalizeRule(tt.rule); got != tt.want {
+			t.Errorf("generalizeRule(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestRelativizeRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"Edit(//wt/a/internal/x.go)", "Edit($WORKTREE/internal/x.go)"},
+		{"Read(/wt/a)", "Read($WORKTREE)"},
+		{"Read(/wt/ab/x)", "Read(/wt/ab/x)"},
+		{"Bash(cat /wt/a/x)", "Bash(cat /wt/a/x)"},
+	}
+
+	for _, tt := range tests {
+		if got := relativizeRule(tt.rule, "/wt/a"); got != tt.want {
+			t.Errorf("relativizeRule(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestGeneralizesAcrossWorktrees(t *testing.T) {
+	obs := []Observation{
+		{Rule: "Bash(go test ./pkg/a)", Repo: "proj", Worktree: "/r/proj/.worktrees/a", SettingsPath: "/a.json"},
+		{Rule: "Bash(go test ./pkg/b)", Repo: "proj", Worktree: "/r/proj/.worktrees/b", SettingsPath: "/b.json"},
+		{Rule: "Bash(make lint)", Repo: "proj", Worktree: "/r/proj/.worktrees/b", SettingsPath: "/b.json"},
+	}
+
+	got := Suggest(obs, t.TempDir(), SuggestOptions{MinWorktrees: 2, MinRequests: 3})
+	if len(got) != 1 {
+		t.Fatalf("expected 1 suggestion, got %+v", got)
+	}
+
+	s := got[0]
+	if s.Rule != "Bash(go test:*)" {
+		t.Errorf("expected Bash(go test:*), got %q", s.Rule)
+	}
+	if s.Global() || !reflect.DeepEqual(s.Repos, []string{"proj"}) {
+		t.Errorf("expected a repo suggestion for proj, got %v", s.Repos)
+	}
+	if s.Worktrees != 2 {
+		t.Errorf("expected 2 worktrees, got %d", s.Worktrees)
+	}
+	if !reflect.DeepEqual(s.Examples, []string{"Bash(go test ./pkg/a)", "Bash(go test ./pkg/b)"}) {
+		t.Errorf("unexpected examples %v", s.Examples)
+	}
+	wantCovers := map[string][]string{
+		"/a.json": {"Bash(go test ./pkg/a)"},
+		"/b.json": {"Bash(go test ./pkg/b)"},
+	}
+	if !reflect.DeepEqual(s.Covers, wantCovers) {
+		t.Errorf("unexpected covers %v", s.Covers)
+	}
+}
+
+func TestSuggestFromAudit(t *testing.T) {
+	var obs []Observation
+	for _, repo := range []string{"a", "b", "b"} {
+		obs = append(obs, Observation{Rule: "Bash(git fetch origin && git status > out.txt)", Repo: repo})
+	}
+
+	got := Suggest(obs, t.TempDir(), SuggestOptions{MinWorktrees: 2, MinRequests: 3})
+
+	var rules []string
+	for _, s := range got {
+		rules = append(rules, s.Rule)
+		if !s.Global() || s.Requests != 3 {
+			t.Errorf("expected a global suggestion from 3 requests, got %+v", s)
+		}
+	}
+	if !reflect.DeepEqual(rules, []string{"Bash(git fetch origin)", "Bash(git status)"}) {
+		t.Errorf("unexpected suggestions %v", rules)
+	}
+}
+
+func TestSuggestSkipsUnsafeRules(t *testing.T) {
+	var obs []Observation
+	for _, wt := range []string{"a", "b"} {
+		for _, rule := range []string{"Bash(python3:*)", "Bash(rm -rf build)", "Bash(*)", "Bash(go test:*)"} {
+			obs = append(obs, Observation{Rule: rule, Repo: "proj", Worktree: "/r/proj/.worktrees/" + wt, SettingsPath: "/" + wt + ".json"})
+		}
+	}
+
+	var rules []string
+	for _, s := range Suggest(obs, t.TempDir(), SuggestOptions{MinWorktrees: 2, MinRequests: 3}) {
+		rules = append(rules, s.Rule)
+	}
+	if want := []string{"Bash(go test:*)", "Bash(rm -rf build)"}; !reflect.DeepEqual(rules, want) {
+		t.Errorf("got %v, want %v", rules, want)
+	}
+}
+
+func TestAuditObservationsOnlyApproved(t *testing.T) {
+	wt, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "init", "-q", wt).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	approved := []Observation{
+		{Rule: "Bash(go test:*)", Repo: "proj", Worktree: wt, SettingsPath: "/s.json"},
+		{Rule: "Bash(git status)", Repo: "proj", Worktree: wt, SettingsPath: "/s.json"},
+	}
+	records := []AuditRecord{
+		{CWD: wt, Permission: "Bash(go test ./...)", Decision: DecisionNone, Repo: "proj"},
+		{CWD: wt, Permission: "Bash(go test ./... && git status)", Decision: DecisionNone, Repo: "proj"},
+		{CWD: wt, Permission: "Bash(rm -rf build)", Decision: DecisionNone, Repo: "proj"},
+		{CWD: wt, Permission: "Bash(go test ./... && rm -rf build)", Decision: DecisionNone, Repo: "proj"},
+		{CWD: wt, Permission: "Bash(go vet ./...)", Decision: ListAllow, Repo: "proj"},
+	}
+
+	var rules []string
+	for _, o := range AuditObservations(records, approved) {
+		rules = append(rules, o.Rule)
+	}
+	want := []string{"Bash(go test ./...)", "Bash(go test ./... && git status)"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got %v, want %v", rules, want)
+	}
+}
+
+func TestSuggestSkipsRulesInTier(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tiersDir, "repos"), 0o755)
+	if err := SaveTierFile(filepath.Join(tiersDir, "repos", "proj.json"), Tier{Allow: []string{"Bash(go test:*)"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	obs := []Observation{
+		{Rule: "Bash(go test ./a)", Repo: "proj", Worktree: "/w/a", SettingsPath: "/a.json"},
+		{Rule: "Bash(go test ./b)", Repo: "proj", Worktree: "/w/b", SettingsPath: "/b.json"},
+	}
+
+	if got := Suggest(obs, tiersDir, SuggestOptions{MinWorktrees: 2}); len(got) != 0 {
+		t.Errorf("expected no suggestions for a rule already in the repo tier, got %+v", got)
+	}
+}
+
+func TestRouteDecisionsCovers(t *testing.T) {
+	tmpDir := t.TempDir()
+	tiersDir := filepath.Join(tmpDir, "tiers")
+
+	settingsPath := filepath.Join(tmpDir, ".claude", "settings.local.json")
+	if err := SaveClaudeSettings(settingsPath, []string{"Read", "Bash(go test ./a)"}); err != nil {
+		t.Fatal(err)
+	}
+
+	decisions := []ReviewDecision{
+		{Rule: "Bash(go test:*)", Action: ReviewPromoteGlobal, Covers: []string{"Bash(go test ./a)"}},
+	}
+	if err := RouteDecisions(tiersDir, "proj", settingsPath, decisions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	global, _ := LoadTierFile(TierPath(tiersDir, "global"))
+	if !reflect.DeepEqual(global.Allow, []string{"Bash(go test:*)"}) {
+		t.Errorf("expected the generalized rule in the global tier, got %v", global.Allow)
+	}
+
+	remaining, _ := LoadClaudeSettings(settingsPath)
+	if !reflect.DeepEqual(remaining, []string{"Read"}) {
+		t.Errorf("expected the covered rule removed from settings, got %v", remaining)
+	}
+}
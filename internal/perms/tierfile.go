@@ -0,0 +1,248 @@
+package perms
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TierExt and LegacyTierExt are the tier file formats. A TOML tier can
+// record a RuleInfo for each rule; a JSON tier holds only the rules.
+const (
+	TierExt       = ".toml"
+	LegacyTierExt = ".json"
+)
+
+// RuleInfo is what a TOML tier records about a rule besides the rule itself:
+// why it is there, who added it and when, the worktree it was reviewed in,
+// and when it stops applying.
+type RuleInfo struct {
+	Reason  string    `toml:"reason,omitempty"`
+	AddedBy string    `toml:"added_by,omitempty"`
+	AddedAt time.Time `toml:"added_at,omitempty"`
+	Source  string    `toml:"source,omitempty"`
+	Expires time.Time `toml:"expires,omitempty"`
+}
+
+func (i RuleInfo) IsZero() bool {
+	return i == RuleInfo{}
+}
+
+// Expired reports whether the rule has stopped applying at now.
+func (i RuleInfo) Expired(now time.Time) bool {
+	return !i.Expires.IsZero() && !now.Before(i.Expires)
+}
+
+// NewRuleInfo returns the provenance of a rule being added now by the
+// current user, reviewed in the source worktree if there is one.
+func NewRuleInfo(reason, source string) RuleInfo {
+	info := RuleInfo{
+		Reason:  reason,
+		AddedAt: time.Now().Truncate(time.Second),
+		Source:  source,
+	}
+	if u, err := user.Current(); err == nil {
+		info.AddedBy = u.Username
+	} else {
+		info.AddedBy = os.Getenv("USER")
+	}
+	return info
+}
+
+// ParseExpires parses an --expires value: a duration after now such as 12h
+// or 30d, or a date or time such as 2026-01-31 or 2026-01-31T09:00:00Z.
+func ParseExpires(s string, now time.Time) (time.Time, error) {
+	t, err := parseTime(s, now, 1)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --expires %q: %w", s, err)
+	}
+	return t.Truncate(time.Second), nil
+}
+
+// Info returns what the tier records about rule in list.
+func (t Tier) Info(list, rule string) RuleInfo {
+	return t.info[list][rule]
+}
+
+// SetInfo records info about rule in list. Only TOML tiers save it.
+func (t *Tier) SetInfo(list, rule string, info RuleInfo) {
+	if info.IsZero() {
+		delete(t.info[list], rule)
+		return
+	}
+	if t.info == nil {
+		t.info = map[string]map[string]RuleInfo{}
+	}
+	if t.info[list] == nil {
+		t.info[list] = map[string]RuleInfo{}
+	}
+	t.info[list][rule] = info
+}
+
+// Active returns the tier without the rules that have expired at now.
+func (t Tier) Active(now time.Time) Tier {
+	active := Tier{info: t.info}
+	for _, list := range Lists {
+		kept := []string{}
+		for _, rule := range *t.List(list) {
+			if !t.Info(list, rule).Expired(now) {
+				kept = append(kept, rule)
+			}
+		}
+		*active.List(list) = kept
+	}
+	return active
+}
+
+// tomlRule is a rule in a TOML tier: either a plain string, or a table with
+// a rule key and the RuleInfo keys.
+type tomlRule struct {
+	Rule string `toml:"rule"`
+	RuleInfo
+}
+
+func (r *tomlRule) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		r.Rule = v
+		return nil
+
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			var err error
+			switch key {
+			case "rule":
+				r.Rule, err = tomlString(key, v[key])
+			case "reason":
+				r.Reason, err = tomlString(key, v[key])
+			case "added_by":
+				r.AddedBy, err = tomlString(key, v[key])
+			case "source":
+				r.Source, err = tomlString(key, v[key])
+			case "added_at":
+				r.AddedAt, err = tomlTime(key, v[key])
+			case "expires":
+				r.Expires, err = tomlTime(key, v[key])
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if r.Rule == "" {
+			return fmt.Errorf("missing rule")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("want a rule string or table, got %T", v)
+}
+
+func tomlString(key string, v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: want a string, got %T", key, v)
+	}
+	return s, nil
+}
+
+// tomlTime accepts TOML dates and datetimes, local ones being taken in the
+// local time zone, as well as strings holding either.
+func tomlTime(key string, v any) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		// The decoder gives local dates and datetimes a placeholder zone.
+		switch v.Location().String() {
+		case "date-local", "datetime-local":
+			return time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.Local), nil
+		}
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: want a date like 2006-01-02 or a datetime, got %v", key, v)
+}
+
+type tomlTier struct {
+	Allow []tomlRule `toml:"allow"`
+	Ask   []tomlRule `toml:"ask"`
+	Deny  []tomlRule `toml:"deny"`
+}
+
+func decodeTOMLTier(data []byte) (Tier, error) {
+	var file tomlTier
+	if err := toml.Unmarshal(data, &file); err != nil {
+		return Tier{}, err
+	}
+
+	tier := Tier{}
+	for list, rules := range map[string][]tomlRule{ListAllow: file.Allow, ListAsk: file.Ask, ListDeny: file.Deny} {
+		names := []string{}
+		for _, r := range rules {
+			names = append(names, r.Rule)
+			tier.SetInfo(list, r.Rule, r.RuleInfo)
+		}
+		*tier.List(list) = names
+	}
+	return tier, nil
+}
+
+// encodeTOMLTier writes each list as an array of strings, or as an array of
+// tables when any of its rules has info.
+func encodeTOMLTier(tier Tier) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+
+	var tables []string
+	for _, list := range Lists {
+		rules := *tier.List(list)
+		if len(rules) > 0 && !hasInfo(tier, list) {
+			if err := enc.Encode(map[string][]string{list: rules}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(rules) > 0 {
+			tables = append(tables, list)
+		}
+	}
+
+	for _, list := range tables {
+		var entries []tomlRule
+		for _, rule := range *tier.List(list) {
+			entries = append(entries, tomlRule{Rule: rule, RuleInfo: tier.Info(list, rule)})
+		}
+		if err := enc.Encode(map[string][]tomlRule{list: entries}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func hasInfo(tier Tier, list string) bool {
+	for _, rule := range *tier.List(list) {
+		if !tier.Info(list, rule).IsZero() {
+			return true
+		}
+	}
+	return false
+}
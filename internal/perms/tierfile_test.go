@@ -0,0 +1,292 @@
+package perms
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTierFileTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "global.toml")
+	os.WriteFile(path, []byte(`allow = ["Read", "Bash(ls)"]
+
+[[deny]]
+rule = "Bash(rm -rf:*)"
+reason = "never wanted"
+
+[[deny]]
+rule = "Bash(curl:*)"
+added_by = "alice"
+added_at = 2026-01-02T03:04:05Z
+source = "/home/alice/eng/worktrees/proj/feature"
+expires = 2026-03-01
+`), 0o644)
+
+	tier, err := LoadTierFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tier.Allow, []string{"Read", "Bash(ls)"}) {
+		t.Errorf("allow = %v", tier.Allow)
+	}
+	if !reflect.DeepEqual(tier.Ask, []string{}) {
+		t.Errorf("ask = %v, want empty", tier.Ask)
+	}
+	if !reflect.DeepEqual(tier.Deny, []string{"Bash(rm -rf:*)", "Bash(curl:*)"}) {
+		t.Errorf("deny = %v", tier.Deny)
+	}
+
+	if info := tier.Info(ListDeny, "Bash(rm -rf:*)"); info.Reason != "never wanted" {
+		t.Errorf("reason = %q", info.Reason)
+	}
+	info := tier.Info(ListDeny, "Bash(curl:*)")
+	if info.AddedBy != "alice" || info.Source != "/home/alice/eng/worktrees/proj/feature" {
+		t.Errorf("unexpected provenance: %+v", info)
+	}
+	if want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC); !info.AddedAt.Equal(want) {
+		t.Errorf("added_at = %v, want %v", info.AddedAt, want)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local); !info.Expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", info.Expires, want)
+	}
+	if !tier.Info(ListAllow, "Read").IsZero() {
+		t.Errorf("expected no info for a plain string rule")
+	}
+}
+
+func TestLoadTierFileTOMLInlineTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "global.toml")
+	os.WriteFile(path, []byte(`allow = [
+  "Read",
+  { rule = "Bash(make:*)", reason = "builds" },
+]
+`), 0o644)
+
+	tier, err := LoadTierFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tier.Allow, []string{"Read", "Bash(make:*)"}) {
+		t.Errorf("allow = %v", tier.Allow)
+	}
+	if got := tier.Info(ListAllow, "Bash(make:*)").Reason; got != "builds" {
+		t.Errorf("reason = %q, want builds", got)
+	}
+}
+
+func TestLoadTierFileTOMLCorrupt(t *testing.T) {
+	for _, bad := range []string{
+		`allow = "Read"`,
+		"[[allow]]\nrule = \"Read\"\nreasons = \"typo\"",
+		"[[allow]]\nreason = \"no rule\"",
+		"[[allow]]\nrule = \"Read\"\nexpires = \"soon\"",
+	} {
+		path := filepath.Join(t.TempDir(), "global.toml")
+		os.WriteFile(path, []byte(bad), 0o644)
+
+		if _, err := LoadTierFile(path); !errors.Is(err, ErrCorruptTier) {
+			t.Errorf("expected ErrCorruptTier for:\n%s\ngot %v", bad, err)
+		}
+	}
+}
+
+func TestSaveTierFileTOMLRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "global.toml")
+
+	tier := Tier{
+		Allow: []string{"Read", "Bash(make:*)"},
+		Ask:   []string{"Bash(git push:*)"},
+		Deny:  []string{},
+	}
+	info := RuleInfo{
+		Reason:  "builds",
+		AddedBy: "alice",
+		AddedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Expires: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tier.SetInfo(ListAllow, "Bash(make:*)", info)
+
+	if err := SaveTierFile(path, tier); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := LoadTierFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, list := range Lists {
+		if !reflect.DeepEqual(*loaded.List(list), *tier.List(list)) {
+			t.Errorf("%s = %v, want %v", list, *loaded.List(list), *tier.List(list))
+		}
+	}
+	if got := loaded.Info(ListAllow, "Bash(make:*)"); !reflect.DeepEqual(got, info) {
+		t.Errorf("info = %+v, want %+v", got, info)
+	}
+}
+
+func TestTierActive(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tier := Tier{Allow: []string{"Read", "Bash(make:*)", "Bash(ls)"}, Ask: []string{}, Deny: []string{}}
+	tier.SetInfo(ListAllow, "Bash(make:*)", RuleInfo{Expires: now})
+	tier.SetInfo(ListAllow, "Bash(ls)", RuleInfo{Expires: now.Add(time.Hour)})
+
+	active := tier.Active(now)
+	if want := []string{"Read", "Bash(ls)"}; !reflect.DeepEqual(active.Allow, want) {
+		t.Errorf("allow = %v, want %v", active.Allow, want)
+	}
+}
+
+func TestCheckIgnoresExpiredRules(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.WriteFile(filepath.Join(tiersDir, "global.toml"), []byte(`
+[[allow]]
+rule = "Bash(make:*)"
+expires = 2020-01-01
+
+[[allow]]
+rule = "Bash(ls)"
+expires = 2999-01-01
+`), 0o644)
+
+	for command, want := range map[string]string{"make build": DecisionNone, "ls": ListAllow} {
+		req := Request{ToolName: "Bash", ToolInput: map[string]any{"command": command}, CWD: "/"}
+		ev, err := Evaluate(tiersDir, req, []string{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ev.Outcome(); got != want {
+			t.Errorf("%s: got %s, want %s", command, got, want)
+		}
+	}
+}
+
+func TestTierPathPrefersTOML(t *testing.T) {
+	tiersDir := t.TempDir()
+
+	if got, want := TierPath(tiersDir, "global"), filepath.Join(tiersDir, "global.toml"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	os.MkdirAll(filepath.Join(tiersDir, "repos"), 0o755)
+	os.WriteFile(filepath.Join(tiersDir, "repos", "proj.json"), []byte(`{"allow": []}`), 0o644)
+	os.WriteFile(filepath.Join(tiersDir, "repos", "proj.toml"), []byte(`allow = []`), 0o644)
+	os.WriteFile(filepath.Join(tiersDir, "repos", "other.json"), []byte(`{"allow": []}`), 0o644)
+
+	if got, want := TierPath(tiersDir, "proj"), filepath.Join(tiersDir, "repos", "proj.toml"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := TierPath(tiersDir, "other"), filepath.Join(tiersDir, "repos", "other.json"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	names, err := RepoTierFiles(tiersDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"other", "proj"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestTierPathStaysInRepos(t *testing.T) {
+	tiersDir := t.TempDir()
+	reposDir := filepath.Join(tiersDir, "repos") + string(filepath.Separator)
+
+	for _, name := range []string{"h/../../global", "../x", "/etc/passwd", "a/../../../b"} {
+		if got := TierPath(tiersDir, name); !strings.HasPrefix(got, reposDir) {
+			t.Errorf("TierPath(%q) = %s, want a file under %s", name, got, reposDir)
+		}
+	}
+}
+
+func TestConvertTierToTOML(t *testing.T) {
+	tiersDir := t.TempDir()
+	SaveTierFile(filepath.Join(tiersDir, "global.json"), Tier{Allow: []string{"Read"}, Ask: []string{}, Deny: []string{}})
+
+	path, err := ConvertTierToTOML(tiersDir, "global")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(tiersDir, "global.toml"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if _, err := os.Stat(filepath.Join(tiersDir, "global.json")); !os.IsNotExist(err) {
+		t.Errorf("expected global.json to be removed, got %v", err)
+	}
+
+	tier, err := LoadTierFile(TierPath(tiersDir, "global"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tier.Allow, []string{"Read"}) {
+		t.Errorf("allow = %v", tier.Allow)
+	}
+}
+
+func TestRouteDecisionsRecordsProvenance(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.WriteFile(filepath.Join(tiersDir, "global.toml"), nil, 0o644)
+
+	info := RuleInfo{
+		AddedBy: "alice",
+		AddedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:  "/home/alice/eng/worktrees/proj/feature",
+	}
+	decisions := []ReviewDecision{{Rule: "Bash(make:*)", Action: ReviewPromoteGlobal, Info: info}}
+	if err := RouteDecisions(tiersDir, "proj", "", decisions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	global, err := LoadTierFile(filepath.Join(tiersDir, "global.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := global.Info(ListAllow, "Bash(make:*)"); !reflect.DeepEqual(got, info) {
+		t.Errorf("info = %+v, want %+v", got, info)
+	}
+}
+
+func TestMoveRuleKeepsInfo(t *testing.T) {
+	tiersDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tiersDir, "repos"), 0o755)
+	os.WriteFile(filepath.Join(tiersDir, "global.toml"), nil, 0o644)
+	os.WriteFile(filepath.Join(tiersDir, "repos", "proj.toml"), []byte("[[allow]]\nrule = \"Bash(make:*)\"\nreason = \"builds\"\n"), 0o644)
+
+	if _, err := MoveRule(tiersDir, "Bash(make:*)", "proj", "global"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	global, _ := LoadTierFile(filepath.Join(tiersDir, "global.toml"))
+	if got := global.Info(ListAllow, "Bash(make:*)").Reason; got != "builds" {
+		t.Errorf("reason = %q, want builds", got)
+	}
+}
+
+func TestParseExpires(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	for in, want := range map[string]time.Time{
+		"12h":                  now.Add(12 * time.Hour),
+		"30d":                  now.AddDate(0, 0, 30),
+		"2026-03-01":           time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"2026-03-01T09:00:00Z": time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+	} {
+		got, err := ParseExpires(in, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", in, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: got %v, want %v", in, got, want)
+		}
+	}
+
+	if _, err := ParseExpires("soon", now); err == nil {
+		t.Error("expected an error for soon")
+	}
+}
@@ -3,66 +3,322 @@ package perms
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
+)
+
+// Rule lists of a tier. Deny rules win over ask and allow rules at any tier,
+// and ask rules win over allow rules, so a broad allow such as Bash(git *)
+// can leave a dangerous subset like Bash(git push --force*) to the user.
+const (
+	ListAllow = "allow"
+	ListAsk   = "ask"
+	ListDeny  = "deny"
 )
 
+// Lists are the rule lists of a tier, in file order.
+var Lists = []string{ListAllow, ListAsk, ListDeny}
+
 type Tier struct {
 	Allow []string `json:"allow"`
+	Ask   []string `json:"ask"`
+	Deny  []string `json:"deny"`
+
+	// info holds the RuleInfo of rules, keyed by list and then rule. Only
+	// TOML tier files record it.
+	info map[string]map[string]RuleInfo
+}
+
+// List returns the rule list called name, or nil for an unknown name.
+func (t *Tier) List(name string) *[]string {
+	switch name {
+	case ListAllow:
+		return &t.Allow
+	case ListAsk:
+		return &t.Ask
+	case ListDeny:
+		return &t.Deny
+	}
+	return nil
+}
+
+// Empty reports whether the tier has no rules in any list.
+func (t Tier) Empty() bool {
+	return len(t.Allow) == 0 && len(t.Ask) == 0 && len(t.Deny) == 0
 }
 
+// ErrCorruptTier is returned for a tier file that is not a valid tier.
+var ErrCorruptTier = errors.New("corrupt tier file")
+
 func LoadTierFile(path string) (Tier, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return Tier{Allow: []string{}}, nil
+			return Tier{Allow: []string{}, Ask: []string{}, Deny: []string{}}, nil
 		}
 		return Tier{}, err
 	}
 
 	var tier Tier
-	if err := json.Unmarshal(data, &tier); err != nil {
-		return Tier{}, err
+	if filepath.Ext(path) == TierExt {
+		tier, err = decodeTOMLTier(data)
+	} else {
+		err = json.Unmarshal(data, &tier)
+	}
+	if err != nil {
+		return Tier{}, fmt.Errorf("%w %s: %v", ErrCorruptTier, path, err)
 	}
 
-	if tier.Allow == nil {
-		tier.Allow = []string{}
+	for _, name := range Lists {
+		if list := tier.List(name); *list == nil {
+			*list = []string{}
+		}
 	}
 
 	return tier, nil
 }
 
+// SaveTierFile writes tier to path atomically, as TOML when path ends in
+// .toml and as JSON otherwise, which drops any RuleInfo. Callers modifying a
+// tier they loaded should go through updateTierFile instead, which also
+// holds the tier's lock.
 func SaveTierFile(path string, tier Tier) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+	var data []byte
+	var err error
+	if filepath.Ext(path) == TierExt {
+		data, err = encodeTOMLTier(tier)
+	} else {
+		data, err = json.MarshalIndent(tier, "", "  ")
+		data = append(data, '\n')
 	}
-
-	data, err := json.MarshalIndent(tier, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	data = append(data, '\n')
+	return filelock.WriteFile(path, data, 0o644)
+}
 
-	return os.WriteFile(path, data, 0o644)
+// updateTierFile loads the tier at path, applies fn and saves the result if
+// fn reports a change, holding the tier's lock throughout so that
+// concurrent sessions do not lose each other's rules.
+func updateTierFile(path string, fn func(*Tier) (bool, error)) error {
+	return filelock.With(path, func() error {
+		tier, err := LoadTierFile(path)
+		if err != nil {
+			return err
+		}
+
+		changed, err := fn(&tier)
+		if err != nil || !changed {
+			return err
+		}
+		return SaveTierFile(path, tier)
+	})
+}
+
+// BackupCorruptTier moves a corrupt tier file at path aside to a timestamped
+// backup next to it, so that it stops failing every hook call while keeping
+// its rules for repair. It returns the backup path, or "" when the file is
+// no longer corrupt by the time the lock is taken.
+func BackupCorruptTier(path string) (string, error) {
+	var backup string
+	err := filelock.With(path, func() error {
+		if _, err := LoadTierFile(path); !errors.Is(err, ErrCorruptTier) {
+			return nil
+		}
+
+		backup = fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102T150405"))
+		return os.Rename(path, backup)
+	})
+	if err != nil {
+		return "", err
+	}
+	return backup, nil
 }
 
 func AppendToTierFile(path string, rule string) error {
-	tier, err := LoadTierFile(path)
+	return AppendToTierList(path, ListAllow, rule)
+}
+
+// AppendToTierList adds rule to the named list of the tier file at path,
+// unless it is already there.
+func AppendToTierList(path, list, rule string) error {
+	_, err := AddRule(path, list, rule)
+	return err
+}
+
+// AddRule is AppendToTierList that reports whether the rule was added.
+func AddRule(path, list, rule string) (bool, error) {
+	return AddRuleWithInfo(path, list, rule, RuleInfo{})
+}
+
+// AddRuleWithInfo is AddRule that also records info about the rule when it
+// is added.
+func AddRuleWithInfo(path, list, rule string, info RuleInfo) (bool, error) {
+	var added bool
+	err := updateTierFile(path, func(tier *Tier) (bool, error) {
+		rules := tier.List(list)
+		if rules == nil {
+			return false, fmt.Errorf("unknown rule list %q", list)
+		}
+
+		for _, existing := range *rules {
+			if existing == rule {
+				return false, nil
+			}
+		}
+
+		*rules = append(*rules, rule)
+		tier.SetInfo(list, rule, info)
+		added = true
+		return true, nil
+	})
+	return added, err
+}
+
+// RemoveRule deletes rule from the named lists of the tier file at path, or
+// from every list when none are named. It returns the lists it was removed
+// from.
+func RemoveRule(path, rule string, lists ...string) ([]string, error) {
+	if len(lists) == 0 {
+		lists = Lists
+	}
+
+	var removed []string
+	err := updateTierFile(path, func(tier *Tier) (bool, error) {
+		for _, list := range lists {
+			rules := tier.List(list)
+			if rules == nil {
+				return false, fmt.Errorf("unknown rule list %q", list)
+			}
+
+			kept := RemoveRules(*rules, []string{rule})
+			if len(kept) != len(*rules) {
+				*rules = kept
+				tier.SetInfo(list, rule, RuleInfo{})
+				removed = append(removed, list)
+			}
+		}
+		return len(removed) > 0, nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return removed, nil
+}
 
-	for _, existing := range tier.Allow {
-		if existing == rule {
-			return nil
+// TierPath returns the file of the named tier: "global", or a repo tier
+// name such as myrepo or github.com/owner/myrepo. An existing JSON file is
+// used until the tier is converted, and a TOML file otherwise, so that new
+// tiers can record RuleInfo. Repo tier files always lie under
+// tiersDir/repos.
+func TierPath(tiersDir, name string) string {
+	// Cleaning name as a rooted path keeps any .. in it from leaving repos/.
+	base := filepath.Join(tiersDir, "repos", filepath.FromSlash(path.Clean("/"+name)))
+	if name == "global" {
+		base = filepath.Join(tiersDir, "global")
+	}
+
+	if _, err := os.Stat(base + TierExt); err == nil {
+		return base + TierExt
+	}
+	if _, err := os.Stat(base + LegacyTierExt); err == nil {
+		return base + LegacyTierExt
+	}
+	return base + TierExt
+}
+
+// ConvertTierToTOML rewrites the named tier as a TOML file so that it can
+// record RuleInfo, removing its JSON file. A tier without a file gets an
+// empty TOML file. It returns the TOML path.
+func ConvertTierToTOML(tiersDir, name string) (string, error) {
+	legacy := TierPath(tiersDir, name)
+	if filepath.Ext(legacy) == TierExt {
+		return legacy, nil
+	}
+	path := strings.TrimSuffix(legacy, LegacyTierExt) + TierExt
+
+	err := filelock.With(legacy, func() error {
+		tier, err := LoadTierFile(legacy)
+		if err != nil {
+			return err
+		}
+		if err := SaveTierFile(path, tier); err != nil {
+			return err
+		}
+		if err := os.Remove(legacy); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
+	return path, nil
+}
 
-	tier.Allow = append(tier.Allow, rule)
+// FindRule returns the tiers in tiersDir whose files hold rule in any list,
+// global first.
+func FindRule(tiersDir, rule string) ([]string, error) {
+	repos, err := RepoTierFiles(tiersDir)
+	if err != nil {
+		return nil, err
+	}
 
-	return SaveTierFile(path, tier)
+	var found []string
+	for _, name := range append([]string{"global"}, repos...) {
+		tier, err := LoadTierFile(TierPath(tiersDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("loading tier %s: %w", name, err)
+		}
+		for _, list := range Lists {
+			if contains(*tier.List(list), rule) {
+				found = append(found, name)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// MoveRule moves rule from tier from to tier to, keeping the lists it is
+// in. It returns those lists.
+func MoveRule(tiersDir, rule, from, to string) ([]string, error) {
+	if from == to {
+		return nil, fmt.Errorf("%s is both source and destination", from)
+	}
+
+	source, err := LoadTierFile(TierPath(tiersDir, from))
+	if err != nil {
+		return nil, err
+	}
+
+	var lists []string
+	for _, list := range Lists {
+		if contains(*source.List(list), rule) {
+			lists = append(lists, list)
+		}
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("%s is not in the %s tier", rule, from)
+	}
+
+	for _, list := range lists {
+		if _, err := AddRuleWithInfo(TierPath(tiersDir, to), list, rule, source.Info(list, rule)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := RemoveRule(TierPath(tiersDir, from), rule, lists...); err != nil {
+		return nil, err
+	}
+	return lists, nil
 }
 
 func TiersDir() string {
@@ -74,12 +330,45 @@ func TiersDir() string {
 	return filepath.Join(home, ".config", "sweatshop", "permissions")
 }
 
-func LoadTiers(tiersDir string, repo string) []string {
-	globalPath := filepath.Join(tiersDir, "global.json")
-	repoPath := filepath.Join(tiersDir, "repos", repo+".json")
+// RepoTierFiles returns the names of all repo tiers in tiersDir, including
+// those keyed by remote URL, which live in nested directories.
+func RepoTierFiles(tiersDir string) ([]string, error) {
+	reposDir := filepath.Join(tiersDir, "repos")
 
-	global, _ := LoadTierFile(globalPath)
-	repoTier, _ := LoadTierFile(repoPath)
+	var names []string
+	seen := map[string]bool{}
+	err := filepath.WalkDir(reposDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		ext := filepath.Ext(d.Name())
+		if d.IsDir() || ext != TierExt && ext != LegacyTierExt {
+			return nil
+		}
+
+		rel, err := filepath.Rel(reposDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(strings.TrimSuffix(rel, ext))
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return nil
+	})
+
+	return names, err
+}
+
+func LoadTiers(tiersDir string, repo string) []string {
+	now := time.Now()
+	global, _ := LoadTierFile(TierPath(tiersDir, "global"))
+	repoTier, _ := LoadTierFile(TierPath(tiersDir, repo))
+	global, repoTier = global.Active(now), repoTier.Active(now)
 
 	seen := map[string]bool{}
 	var merged []string
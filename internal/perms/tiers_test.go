@@ -2,8 +2,12 @@ package perms
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -144,3 +148,165 @@ func TestAppendToTierFileNoDuplicates(t *testing.T) {
 		t.Fatalf("expected 1 rule (no duplicate), got %d", len(loaded.Allow))
 	}
 }
+
+func TestAppendToTierList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tier.json")
+
+	if err := AppendToTierList(path, ListDeny, "Bash(rm -rf:*)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := AppendToTierList(path, ListAsk, "Bash(git push:*)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := AppendToTierList(path, "maybe", "Read"); err == nil {
+		t.Error("expected error for unknown list")
+	}
+
+	loaded, err := LoadTierFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+	if len(loaded.Allow) != 0 {
+		t.Errorf("expected no allow rules, got %v", loaded.Allow)
+	}
+	if len(loaded.Deny) != 1 || loaded.Deny[0] != "Bash(rm -rf:*)" {
+		t.Errorf("expected deny rule, got %v", loaded.Deny)
+	}
+	if len(loaded.Ask) != 1 || loaded.Ask[0] != "Bash(git push:*)" {
+		t.Errorf("expected ask rule, got %v", loaded.Ask)
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tier.json")
+
+	added, err := AddRule(path, ListAllow, "Bash(make:*)")
+	if err != nil || !added {
+		t.Fatalf("expected the rule to be added, got %v, %v", added, err)
+	}
+	added, err = AddRule(path, ListAllow, "Bash(make:*)")
+	if err != nil || added {
+		t.Errorf("expected a duplicate to be left alone, got %v, %v", added, err)
+	}
+}
+
+func TestRemoveRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tier.json")
+	SaveTierFile(path, Tier{
+		Allow: []string{"Read", "Bash(git *)"},
+		Ask:   []string{"Bash(git *)"},
+		Deny:  []string{},
+	})
+
+	removed, err := RemoveRule(path, "Bash(git *)", ListAsk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{ListAsk}) {
+		t.Errorf("expected removal from ask only, got %v", removed)
+	}
+
+	removed, err = RemoveRule(path, "Bash(git *)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{ListAllow}) {
+		t.Errorf("expected removal from allow, got %v", removed)
+	}
+
+	removed, err = RemoveRule(path, "Bash(git *)")
+	if err != nil || removed != nil {
+		t.Errorf("expected nothing left to remove, got %v, %v", removed, err)
+	}
+
+	loaded, _ := LoadTierFile(path)
+	if !reflect.DeepEqual(loaded.Allow, []string{"Read"}) || len(loaded.Ask) != 0 {
+		t.Errorf("unexpected tier after removal: %+v", loaded)
+	}
+}
+
+func TestMoveRule(t *testing.T) {
+	tiersDir := t.TempDir()
+	SaveTierFile(TierPath(tiersDir, "github.com/owner/proj"), Tier{
+		Allow: []string{"Bash(make:*)"},
+		Ask:   []string{},
+		Deny:  []string{"Bash(make:*)"},
+	})
+
+	found, err := FindRule(tiersDir, "Bash(make:*)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(found, []string{"github.com/owner/proj"}) {
+		t.Errorf("expected the rule in the repo tier, got %v", found)
+	}
+
+	lists, err := MoveRule(tiersDir, "Bash(make:*)", "github.com/owner/proj", "global")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(lists, []string{ListAllow, ListDeny}) {
+		t.Errorf("expected both lists to move, got %v", lists)
+	}
+
+	global, _ := LoadTierFile(TierPath(tiersDir, "global"))
+	repo, _ := LoadTierFile(TierPath(tiersDir, "github.com/owner/proj"))
+	if !reflect.DeepEqual(global.Allow, []string{"Bash(make:*)"}) || !reflect.DeepEqual(global.Deny, []string{"Bash(make:*)"}) {
+		t.Errorf("expected the rule in global allow and deny, got %+v", global)
+	}
+	if !repo.Empty() {
+		t.Errorf("expected the repo tier emptied, got %+v", repo)
+	}
+
+	if _, err := MoveRule(tiersDir, "Bash(make:*)", "github.com/owner/proj", "global"); err == nil {
+		t.Error("expected an error moving a rule the source does not hold")
+	}
+}
+
+func TestAddRuleConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tier.json")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := AddRule(path, ListAllow, fmt.Sprintf("Bash(task %d)", i)); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := LoadTierFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+	if len(loaded.Allow) != 20 {
+		t.Errorf("expected all 20 rules to survive concurrent writes, got %d", len(loaded.Allow))
+	}
+}
+
+func TestBackupCorruptTier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "global.json")
+	os.WriteFile(path, []byte(`{"allow": ["Read"`), 0o644)
+
+	if _, err := LoadTierFile(path); !errors.Is(err, ErrCorruptTier) {
+		t.Fatalf("expected ErrCorruptTier, got %v", err)
+	}
+
+	backup, err := BackupCorruptTier(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(backup); err != nil || string(data) != `{"allow": ["Read"` {
+		t.Errorf("expected the corrupt contents in %s, got %q, %v", backup, data, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be moved aside, got %v", path, err)
+	}
+
+	if backup, err := BackupCorruptTier(path); err != nil || backup != "" {
+		t.Errorf("expected no backup of a healthy tier, got %q, %v", backup, err)
+	}
+}
@@ -0,0 +1,308 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/amarbel-llc/sweatshop/internal/filelock"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// Ports are handed out from [RangeStart, RangeEnd).
+const (
+	RangeStart = 40000
+	RangeEnd   = 50000
+)
+
+// Block is a run of Count consecutive ports starting at Port.
+type Block struct {
+	Port  int `json:"port"`
+	Count int `json:"count"`
+}
+
+// Allocation maps each sweatfile port name to its block.
+type Allocation map[string]Block
+
+// Registry records the allocation of every worktree, keyed by worktree path.
+type Registry struct {
+	Worktrees map[string]Allocation `json:"worktrees"`
+}
+
+var reName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// EnvName returns the variable a port name is exported as, e.g. "web" is
+// PORT_WEB.
+func EnvName(name string) string {
+	return "PORT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Env returns PORT_<NAME>=<port> for every block, sorted by name. For blocks
+// of more than one port, the variable holds the first.
+func (a Allocation) Env() []string {
+	var env []string
+	for _, name := range a.names() {
+		env = append(env, fmt.Sprintf("%s=%d", EnvName(name), a[name].Port))
+	}
+	return env
+}
+
+// String summarizes the allocation, e.g. "api=40001 web=40002-40004".
+func (a Allocation) String() string {
+	var parts []string
+	for _, name := range a.names() {
+		b := a[name]
+		if b.Count > 1 {
+			parts = append(parts, fmt.Sprintf("%s=%d-%d", name, b.Port, b.Port+b.Count-1))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%d", name, b.Port))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a Allocation) names() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bounds returns the lowest port of a and one past its highest.
+func (a Allocation) bounds() (int, int) {
+	lo, hi := 0, 0
+	for _, b := range a {
+		if lo == 0 || b.Port < lo {
+			lo = b.Port
+		}
+		if b.Port+b.Count > hi {
+			hi = b.Port + b.Count
+		}
+	}
+	return lo, hi
+}
+
+// layout places the blocks of spec next to each other from start, in name
+// order. Names with a count of zero are skipped.
+func layout(spec map[string]int, start int) Allocation {
+	names := make([]string, 0, len(spec))
+	for name := range spec {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	a := Allocation{}
+	port := start
+	for _, name := range names {
+		if spec[name] <= 0 {
+			continue
+		}
+		a[name] = Block{Port: port, Count: spec[name]}
+		port += spec[name]
+	}
+	return a
+}
+
+func validate(spec map[string]int) (int, error) {
+	size := 0
+	for name, count := range spec {
+		if !reName.MatchString(name) {
+			return 0, fmt.Errorf("invalid port name %q", name)
+		}
+		if count < 0 {
+			return 0, fmt.Errorf("port %s: negative count %d", name, count)
+		}
+		size += count
+	}
+	if size > RangeEnd-RangeStart {
+		return 0, fmt.Errorf("requested %d ports, more than the %d available", size, RangeEnd-RangeStart)
+	}
+	return size, nil
+}
+
+// Allocate returns the allocation for worktree, reusing the registered one
+// when it still matches spec. Otherwise a new contiguous block is chosen,
+// starting from a slot derived from the worktree path so that the same
+// worktree tends to get the same ports, and probing upwards past blocks held
+// by other worktrees.
+func (r *Registry) Allocate(worktree string, spec map[string]int) (Allocation, error) {
+	size, err := validate(spec)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		r.Release(worktree)
+		return Allocation{}, nil
+	}
+
+	if existing, ok := r.Worktrees[worktree]; ok {
+		lo, _ := existing.bounds()
+		if want := layout(spec, lo); equal(existing, want) {
+			return existing, nil
+		}
+	}
+
+	span := RangeEnd - RangeStart - size + 1
+	h := fnv.New32a()
+	h.Write([]byte(worktree))
+	slot := int(h.Sum32() % uint32(span))
+
+	for i := 0; i < span; i++ {
+		start := RangeStart + (slot+i)%span
+		if r.overlaps(worktree, start, start+size) {
+			continue
+		}
+		a := layout(spec, start)
+		if r.Worktrees == nil {
+			r.Worktrees = map[string]Allocation{}
+		}
+		r.Worktrees[worktree] = a
+		return a, nil
+	}
+
+	return nil, fmt.Errorf("no free block of %d ports in %d-%d", size, RangeStart, RangeEnd-1)
+}
+
+func (r *Registry) overlaps(worktree string, lo, hi int) bool {
+	for other, a := range r.Worktrees {
+		if other == worktree {
+			continue
+		}
+		olo, ohi := a.bounds()
+		if lo < ohi && olo < hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Release forgets worktree's allocation.
+func (r *Registry) Release(worktree string) {
+	delete(r.Worktrees, worktree)
+}
+
+func equal(a, b Allocation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, block := range a {
+		if b[name] != block {
+			return false
+		}
+	}
+	return true
+}
+
+// RegistryPath is where allocations are stored.
+func RegistryPath() string {
+	return filepath.Join(worktree.GlobalStateDir(), "ports.json")
+}
+
+func Load(path string) (Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Registry{}, nil
+		}
+		return Registry{}, err
+	}
+
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Registry{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Save writes r to path through a temporary file so that readers never see
+// a partial registry.
+func Save(path string, r Registry) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return filelock.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// update loads the registry at path, applies fn and saves the result, holding
+// an exclusive lock so that concurrent sweatshop processes do not hand out
+// the same ports.
+func update(path string, fn func(*Registry) error) error {
+	return filelock.With(path, func() error {
+		r, err := Load(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+		return Save(path, r)
+	})
+}
+
+// Allocate reserves ports for worktree in the registry at RegistryPath. An
+// empty spec releases any ports the worktree held.
+func Allocate(worktree string, spec map[string]int) (Allocation, error) {
+	size, err := validate(spec)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return Allocation{}, Release(worktree)
+	}
+
+	var a Allocation
+	err = update(RegistryPath(), func(r *Registry) error {
+		var err error
+		a, err = r.Allocate(worktree, spec)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("allocating ports: %w", err)
+	}
+	return a, nil
+}
+
+// Lookup returns worktree's current allocation without changing anything.
+func Lookup(worktree string) (Allocation, bool) {
+	r, err := Load(RegistryPath())
+	if err != nil {
+		return nil, false
+	}
+	a, ok := r.Worktrees[worktree]
+	return a, ok
+}
+
+// Release frees worktree's ports. Releasing an unknown worktree is a no-op.
+func Release(worktree string) error {
+	if _, ok := Lookup(worktree); !ok {
+		return nil
+	}
+	return update(RegistryPath(), func(r *Registry) error {
+		r.Release(worktree)
+		return nil
+	})
+}
+
+// Move transfers the allocation of from to to, e.g. after a worktree rename.
+func Move(from, to string) error {
+	if _, ok := Lookup(from); !ok {
+		return nil
+	}
+	return update(RegistryPath(), func(r *Registry) error {
+		if a, ok := r.Worktrees[from]; ok {
+			delete(r.Worktrees, from)
+			r.Worktrees[to] = a
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package ports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocateDeterministic(t *testing.T) {
+	spec := map[string]int{"web": 1, "api": 2}
+
+	var a, b Registry
+	first, err := a.Allocate("/repo/.worktrees/feat", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := b.Allocate("/repo/.worktrees/feat", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same allocation from an empty registry, got %v and %v", first, second)
+	}
+
+	if first["api"].Count != 2 || first["web"].Port != first["api"].Port+2 {
+		t.Errorf("expected api block followed by web, got %v", first)
+	}
+}
+
+func TestAllocateNoOverlap(t *testing.T) {
+	var r Registry
+	spec := map[string]int{"web": 3}
+
+	seen := map[int]string{}
+	for _, wt := range []string{"/r/.worktrees/a", "/r/.worktrees/b", "/r/.worktrees/c", "/r/.worktrees/d"} {
+		a, err := r.Allocate(wt, spec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b := a["web"]
+		if b.Port < RangeStart || b.Port+b.Count > RangeEnd {
+			t.Errorf("%s: block %v outside range", wt, b)
+		}
+		for p := b.Port; p < b.Port+b.Count; p++ {
+			if other, ok := seen[p]; ok {
+				t.Errorf("port %d given to both %s and %s", p, other, wt)
+			}
+			seen[p] = wt
+		}
+	}
+}
+
+func TestAllocateReusesMatchingSpec(t *testing.T) {
+	var r Registry
+	wt := "/r/.worktrees/a"
+
+	first, _ := r.Allocate(wt, map[string]int{"web": 1})
+	again, _ := r.Allocate(wt, map[string]int{"web": 1})
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("expected unchanged spec to keep its ports, got %v then %v", first, again)
+	}
+
+	grown, err := r.Allocate(wt, map[string]int{"web": 1, "db": 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := grown["db"]; !ok {
+		t.Errorf("expected db in reallocated block, got %v", grown)
+	}
+
+	empty, _ := r.Allocate(wt, map[string]int{"web": 0})
+	if len(empty) != 0 {
+		t.Errorf("expected zero counts to allocate nothing, got %v", empty)
+	}
+	if _, ok := r.Worktrees[wt]; ok {
+		t.Error("expected empty spec to release the worktree")
+	}
+}
+
+func TestAllocateInvalid(t *testing.T) {
+	var r Registry
+	if _, err := r.Allocate("/wt", map[string]int{"web server": 1}); err == nil {
+		t.Error("expected error for name with a space")
+	}
+	if _, err := r.Allocate("/wt", map[string]int{"web": -1}); err == nil {
+		t.Error("expected error for negative count")
+	}
+}
+
+func TestEnvAndString(t *testing.T) {
+	a := Allocation{
+		"web":    {Port: 41230, Count: 1},
+		"dev-db": {Port: 41231, Count: 3},
+	}
+
+	want := []string{"PORT_DEV_DB=41231", "PORT_WEB=41230"}
+	if got := a.Env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+	if got := a.String(); got != "dev-db=41231-41233 web=41230" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	t.Setenv("SWEATSHOP_STATE_DIR", t.TempDir())
+
+	a, err := Allocate("/r/.worktrees/a", map[string]int{"web": 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok := Lookup("/r/.worktrees/a")
+	if !ok || !reflect.DeepEqual(got, a) {
+		t.Errorf("Lookup() = %v, %v; want %v", got, ok, a)
+	}
+
+	if err := Move("/r/.worktrees/a", "/r/.worktrees/b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := Lookup("/r/.worktrees/a"); ok {
+		t.Error("expected old path to be gone after Move")
+	}
+
+	if err := Release("/r/.worktrees/b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := Lookup("/r/.worktrees/b"); ok {
+		t.Error("expected allocation to be released")
+	}
+}
@@ -0,0 +1,24 @@
+// Package shellquote quotes arguments for commands that are run through a
+// POSIX shell, such as nix-shell --run and Claude Code hooks.
+package shellquote
+
+import "strings"
+
+// Join quotes each argument for a POSIX shell and joins them with spaces.
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n\"'\\$`!*?[]{}()<>|&;#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,11 @@
+package shellquote
+
+import "testing"
+
+func TestJoin(t *testing.T) {
+	got := Join([]string{"claude", "-p", "fix it's tests", ""})
+	want := `claude -p 'fix it'\''s tests' ''`
+	if got != want {
+		t.Errorf("Join() = %q, want %q", got, want)
+	}
+}
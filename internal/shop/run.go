@@ -0,0 +1,199 @@
+package shop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/amarbel-llc/sweatshop/internal/agent"
+	"github.com/amarbel-llc/sweatshop/internal/executor"
+	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// RunsDirName holds headless run logs and records inside the worktree state dir.
+const RunsDirName = "runs"
+
+type RunOptions struct {
+	Agent  string
+	Prompt string
+}
+
+// RunRecord describes a finished headless run. It is written as JSON next to
+// the run's log file.
+type RunRecord struct {
+	Agent     string    `json:"agent,omitempty"`
+	Prompt    string    `json:"prompt"`
+	Command   []string  `json:"command"`
+	Log       string    `json:"log"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	ExitCode  int       `json:"exit_code"`
+}
+
+// Run creates the worktree if needed, runs the agent headlessly with
+// opts.Prompt, and prints the close-shop summary once it finishes.
+func Run(rp worktree.ResolvedPath, format string, opts RunOptions) error {
+	if err := Create(rp, CreateOptions{Agent: opts.Agent}); err != nil {
+		return err
+	}
+
+	record, err := RunHeadless(rp, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Info("agent finished",
+		"exit", record.ExitCode,
+		"duration", time.Duration(record.Duration*float64(time.Second)).Round(time.Second),
+		"log", record.Log,
+	)
+
+	if err := CloseShop(rp, format); err != nil {
+		return err
+	}
+
+	if record.ExitCode != 0 {
+		return fmt.Errorf("agent exited with status %d", record.ExitCode)
+	}
+	return nil
+}
+
+// RunHeadless runs the agent in an existing worktree without a terminal. Its
+// output goes to a timestamped log in the worktree state dir, and a
+// RunRecord with the exit status and duration is saved beside it. A non-zero
+// agent exit is reported in the record, not as an error.
+func RunHeadless(rp worktree.ResolvedPath, opts RunOptions) (RunRecord, error) {
+	sf, err := loadSweatfile(rp.RepoPath)
+	if err != nil {
+		return RunRecord{}, err
+	}
+
+	a, err := agent.Resolve(sf, opts.Agent)
+	if err != nil {
+		return RunRecord{}, err
+	}
+
+	command, err := a.BuildPromptCommand(agentContext(rp), opts.Prompt)
+	if err != nil {
+		return RunRecord{}, err
+	}
+
+	command, err = sessionCommand(rp, sf, command)
+	if err != nil {
+		return RunRecord{}, err
+	}
+
+	stateDir, err := worktree.StateDir(rp.AbsPath)
+	if err != nil {
+		return RunRecord{}, fmt.Errorf("resolving state dir: %w", err)
+	}
+	runsDir := filepath.Join(stateDir, RunsDirName)
+	if err := os.MkdirAll(runsDir, 0o755); err != nil {
+		return RunRecord{}, err
+	}
+
+	started := time.Now()
+	base := filepath.Join(runsDir, started.Format("20060102T150405.000"))
+	logPath := base + ".log"
+
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return RunRecord{}, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = rp.AbsPath
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return RunRecord{}, fmt.Errorf("starting agent: %w", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	record := RunRecord{
+		Agent:     a.Name,
+		Prompt:    opts.Prompt,
+		Command:   command,
+		Log:       logPath,
+		StartedAt: started,
+		Duration:  time.Since(started).Seconds(),
+		ExitCode:  exitCode,
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return RunRecord{}, err
+	}
+	if err := os.WriteFile(base+".json", append(data, '\n'), 0o644); err != nil {
+		return RunRecord{}, fmt.Errorf("writing run record: %w", err)
+	}
+
+	return record, nil
+}
+
+// Spawn starts the agent with opts.Prompt as its initial prompt in a
+// background session, without attaching to it. The worktree must exist.
+func Spawn(spawner executor.Spawner, rp worktree.ResolvedPath, opts RunOptions) error {
+	sf, err := loadSweatfile(rp.RepoPath)
+	if err != nil {
+		return err
+	}
+
+	a, err := agent.Resolve(sf, opts.Agent)
+	if err != nil {
+		return err
+	}
+
+	command, err := a.BuildCommand(agentContext(rp), []string{opts.Prompt})
+	if err != nil {
+		return err
+	}
+
+	command, err = sessionCommand(rp, sf, command)
+	if err != nil {
+		return err
+	}
+
+	return spawner.Spawn(rp.AbsPath, rp.SessionKey, command)
+}
+
+// LastRun returns the most recent headless run record for the worktree at
+// path, or false when it has never been run.
+func LastRun(path string) (RunRecord, bool) {
+	gitDir, err := git.GitDir(path)
+	if err != nil {
+		return RunRecord{}, false
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(gitDir, worktree.StateDirName, RunsDirName, "*.json"))
+	if len(matches) == 0 {
+		return RunRecord{}, false
+	}
+
+	// Record names are timestamps, so the lexically last one is the newest.
+	sort.Strings(matches)
+	data, err := os.ReadFile(matches[len(matches)-1])
+	if err != nil {
+		return RunRecord{}, false
+	}
+
+	var record RunRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return RunRecord{}, false
+	}
+	return record, true
+}
@@ -3,30 +3,101 @@ package shop
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 
+	"github.com/amarbel-llc/sweatshop/internal/agent"
+	"github.com/amarbel-llc/sweatshop/internal/devenv"
 	"github.com/amarbel-llc/sweatshop/internal/executor"
-	"github.com/amarbel-llc/sweatshop/internal/flake"
 	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/hook"
+	"github.com/amarbel-llc/sweatshop/internal/ports"
 	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
 	"github.com/amarbel-llc/sweatshop/internal/tap"
 	"github.com/amarbel-llc/sweatshop/internal/worktree"
 )
 
-func Create(rp worktree.ResolvedPath, verbose bool) error {
+type CreateOptions struct {
+	Verbose  bool   // log sweatfile loading details
+	Prebuild bool   // build the dev environment even if the sweatfile does not ask to
+	Agent    string // agent profile whose setup (e.g. claude trust) to apply
+	Base     string // ref a new branch starts from; empty uses HEAD
+}
+
+// Create creates the worktree for rp if it does not exist yet and changes into
+// it. The dev environment is prebuilt when opts.Prebuild is set or when a
+// newly created worktree's sweatfile asks for it.
+func Create(rp worktree.ResolvedPath, opts CreateOptions) error {
+	if err := Ensure(rp, opts); err != nil {
+		return err
+	}
+
+	return os.Chdir(rp.AbsPath)
+}
+
+// Ensure is Create without changing the working directory, for callers that
+// prepare several worktrees at once.
+func Ensure(rp worktree.ResolvedPath, opts CreateOptions) error {
+	prebuild := opts.Prebuild
 	if _, err := os.Stat(rp.AbsPath); os.IsNotExist(err) {
-		result, err := worktree.Create(rp.RepoPath, rp.AbsPath)
+		result, err := worktree.Create(rp.RepoPath, rp.AbsPath, worktree.CreateOptions{
+			Agent:  opts.Agent,
+			Branch: rp.Branch,
+			Base:   opts.Base,
+		})
 		if err != nil {
 			return err
 		}
-		if verbose {
+		if opts.Verbose {
 			logSweatfileResult(result)
 		}
+		if _, err := ports.Allocate(rp.AbsPath, result.Merged.Ports); err != nil {
+			return err
+		}
+		prebuild = prebuild || result.Merged.PrebuildDevEnv()
 	}
 
-	return os.Chdir(rp.AbsPath)
+	if prebuild {
+		sf, err := loadSweatfile(rp.RepoPath)
+		if err != nil {
+			return err
+		}
+		if err := prebuildDevEnv(rp.AbsPath, sf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadSweatfile(repoPath string) (sweatfile.Sweatfile, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return sweatfile.Sweatfile{}, fmt.Errorf("getting home directory: %w", err)
+	}
+
+	result, err := sweatfile.LoadHierarchy(home, repoPath)
+	if err != nil {
+		return sweatfile.Sweatfile{}, fmt.Errorf("loading sweatfile: %w", err)
+	}
+
+	return result.Merged, nil
+}
+
+func prebuildDevEnv(worktreePath string, sf sweatfile.Sweatfile) error {
+	provider, err := devenv.Resolve(worktreePath, sf.DevEnv, sf.Direnv)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return nil
+	}
+
+	log.Info("prebuilding dev environment", "devenv", provider.Name())
+	return devenv.Prebuild(provider, worktreePath)
 }
 
 func logSweatfileResult(result sweatfile.LoadResult) {
@@ -39,6 +110,18 @@ func logSweatfileResult(result sweatfile.LoadResult) {
 			if len(src.File.ClaudeAllow) > 0 {
 				log.Info("  claude_allow", "values", src.File.ClaudeAllow)
 			}
+			if src.File.DevEnv != "" {
+				log.Info("  devenv", "value", src.File.DevEnv)
+			}
+			if src.File.Direnv != "" {
+				log.Info("  direnv", "value", src.File.Direnv)
+			}
+			if src.File.Agent.Command != "" {
+				log.Info("  agent", "command", src.File.Agent.Command)
+			}
+			if len(src.File.Ports) > 0 {
+				log.Info("  ports", "values", src.File.Ports)
+			}
 		} else {
 			log.Info("sweatfile not found (skipped)", "path", src.Path)
 		}
@@ -47,25 +130,72 @@ func logSweatfileResult(result sweatfile.LoadResult) {
 	log.Info("merged sweatfile",
 		"git_excludes", merged.GitExcludes,
 		"claude_allow", merged.ClaudeAllow,
+		"devenv", merged.DevEnv,
+		"devenv_prebuild", merged.PrebuildDevEnv(),
+		"direnv", merged.Direnv,
 	)
 }
 
-func Attach(exec executor.Executor, rp worktree.ResolvedPath, format string, claudeArgs []string) error {
-	if err := Create(rp, false); err != nil {
+type AttachOptions struct {
+	// Agent names an [agents.<name>] profile. Empty selects [agent].
+	Agent string
+	// AgentArgs are appended to the agent's configured args.
+	AgentArgs []string
+	// Nest allows attaching from inside another sweatshop session.
+	Nest bool
+	// Record captures the session to a transcript even when the sweatfile
+	// does not set record.
+	Record bool
+	// Resume continues the agent's last conversation in the worktree, as
+	// recorded by its session hooks. It implies launching the agent.
+	Resume bool
+}
+
+// Attach creates the worktree if needed and attaches a session to it. The
+// agent is launched when a profile is named or agent args are given;
+// otherwise the session runs $SHELL.
+//
+// When already inside a sweatshop session, Attach refuses rather than nest
+// a client inside it, unless opts.Nest is set.
+func Attach(exec executor.Executor, rp worktree.ResolvedPath, format string, opts AttachOptions) error {
+	if current := executor.CurrentSession(); current != "" && !opts.Nest {
+		if current == rp.SessionKey {
+			return fmt.Errorf("already inside session %s", current)
+		}
+		return fmt.Errorf("already inside session %s; exit it first or pass --nest to attach %s inside it", current, rp.SessionKey)
+	}
+
+	if err := Create(rp, CreateOptions{Agent: opts.Agent}); err != nil {
+		return err
+	}
+
+	sf, err := loadSweatfile(rp.RepoPath)
+	if err != nil {
 		return err
 	}
 
 	var command []string
-	if len(claudeArgs) > 0 {
-		if flake.HasDevShell(rp.AbsPath) {
-			log.Info("flake.nix detected, starting claude in nix develop")
-			command = append([]string{"nix", "develop", "--command", "claude"}, claudeArgs...)
-		} else {
-			command = append([]string{"claude"}, claudeArgs...)
+	if opts.Agent != "" || len(opts.AgentArgs) > 0 || opts.Resume {
+		a, err := agent.Resolve(sf, opts.Agent)
+		if err != nil {
+			return err
+		}
+		command, err = agentCommand(a, rp, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	command, err = sessionCommand(rp, sf, command)
+	if err != nil {
+		return err
+	}
+
+	if opts.Record || sf.RecordSessions() {
+		exec, err = withRecording(exec, rp)
+		if err != nil {
+			return err
 		}
-	} else if flake.HasDevShell(rp.AbsPath) {
-		log.Info("flake.nix detected, starting session in nix develop")
-		command = []string{"nix", "develop", "--command", os.Getenv("SHELL")}
 	}
 
 	if err := exec.Attach(rp.AbsPath, rp.SessionKey, command); err != nil {
@@ -75,24 +205,110 @@ func Attach(exec executor.Executor, rp worktree.ResolvedPath, format string, cla
 	return CloseShop(rp, format)
 }
 
-func CloseShop(rp worktree.ResolvedPath, format string) error {
-	if rp.Branch == "" {
-		if err := rp.FillBranchFromGit(); err != nil {
-			log.Warn("could not determine current branch")
-			return nil
-		}
+func agentCommand(a agent.Agent, rp worktree.ResolvedPath, opts AttachOptions) ([]string, error) {
+	if !opts.Resume {
+		return a.BuildCommand(agentContext(rp), opts.AgentArgs)
 	}
 
-	defaultBranch, err := git.BranchCurrent(rp.RepoPath)
-	if err != nil || defaultBranch == "" {
-		log.Warn("could not determine default branch")
-		return nil
+	state, ok := hook.LoadState(rp.AbsPath)
+	if !ok {
+		log.Warn("no previous conversation recorded, starting a new one", "worktree", rp.SessionKey)
+		return a.BuildCommand(agentContext(rp), opts.AgentArgs)
 	}
 
-	commitsAhead := git.CommitsAhead(rp.AbsPath, defaultBranch, rp.Branch)
-	worktreeStatus := git.StatusPorcelain(rp.AbsPath)
+	log.Info("resuming conversation", "session", state.SessionID, "last_active", state.LastActive.Format(time.DateTime))
+	return a.BuildResumeCommand(agentContext(rp), state.SessionID, opts.AgentArgs)
+}
+
+// sessionCommand prepares command to run in rp: an empty command becomes
+// $SHELL, the session environment is exported, and the result is wrapped in
+// the worktree's dev environment, if any. The environment is applied inside
+// the dev environment so that its shell hooks cannot override it.
+func sessionCommand(rp worktree.ResolvedPath, sf sweatfile.Sweatfile, command []string) ([]string, error) {
+	if len(command) == 0 {
+		command = []string{os.Getenv("SHELL")}
+	}
+
+	// Worktrees created before the scratch dir existed get it on first use.
+	if err := os.MkdirAll(scratchDir(rp), 0o755); err != nil {
+		return nil, fmt.Errorf("creating scratch dir: %w", err)
+	}
+
+	env, err := sessionEnv(rp, sf)
+	if err != nil {
+		return nil, err
+	}
+	command = withEnv(env, command)
+
+	return wrapDevEnv(rp.AbsPath, sf, command)
+}
+
+// ScratchEnvVar names the per-worktree scratch directory in sessions. TMPDIR
+// is pointed at the same directory so temp files stay inside the worktree.
+const ScratchEnvVar = "SWEATSHOP_TMP"
+
+func scratchDir(rp worktree.ResolvedPath) string {
+	return filepath.Join(rp.AbsPath, sweatfile.ScratchDirName)
+}
+
+// sessionEnv returns the variables exported into every session for rp,
+// allocating the sweatfile's ports if the worktree does not hold them yet.
+func sessionEnv(rp worktree.ResolvedPath, sf sweatfile.Sweatfile) ([]string, error) {
+	allocation, err := ports.Allocate(rp.AbsPath, sf.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch := scratchDir(rp)
+	env := []string{
+		executor.SessionEnvVar + "=" + rp.SessionKey,
+		ScratchEnvVar + "=" + scratch,
+		"TMPDIR=" + scratch,
+	}
+	return append(env, allocation.Env()...), nil
+}
+
+// withEnv prefixes command with env(1) setting vars, reusing an existing env
+// prefix rather than stacking a second one.
+func withEnv(vars []string, command []string) []string {
+	if len(vars) == 0 {
+		return command
+	}
+	if len(command) > 0 && command[0] == "env" {
+		command = command[1:]
+	}
+	return append(append([]string{"env"}, vars...), command...)
+}
+
+// wrapDevEnv wraps command in the worktree's dev environment, if any.
+func wrapDevEnv(worktreePath string, sf sweatfile.Sweatfile, command []string) ([]string, error) {
+	provider, err := devenv.Resolve(worktreePath, sf.DevEnv, sf.Direnv)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return command, nil
+	}
+
+	log.Info("dev environment detected, starting session inside it", "devenv", provider.Name())
+	return provider.Wrap(worktreePath, command), nil
+}
+
+func agentContext(rp worktree.ResolvedPath) agent.Context {
+	return agent.Context{
+		Worktree:   rp.AbsPath,
+		Repo:       rp.RepoPath,
+		Branch:     rp.Branch,
+		SessionKey: rp.SessionKey,
+	}
+}
 
-	desc := statusDescription(defaultBranch, commitsAhead, worktreeStatus)
+func CloseShop(rp worktree.ResolvedPath, format string) error {
+	desc, err := Describe(&rp)
+	if err != nil {
+		log.Warn(err.Error())
+		return nil
+	}
 
 	if format == "tap" {
 		tw := tap.NewWriter(os.Stdout)
@@ -105,6 +321,26 @@ func CloseShop(rp worktree.ResolvedPath, format string) error {
 	return nil
 }
 
+// Describe summarizes how rp's branch compares to the repo's default branch,
+// e.g. "2 commits ahead of main, clean". It fills rp.Branch from git if unset.
+func Describe(rp *worktree.ResolvedPath) (string, error) {
+	if rp.Branch == "" {
+		if err := rp.FillBranchFromGit(); err != nil {
+			return "", fmt.Errorf("could not determine current branch")
+		}
+	}
+
+	defaultBranch, err := git.BranchCurrent(rp.RepoPath)
+	if err != nil || defaultBranch == "" {
+		return "", fmt.Errorf("could not determine default branch")
+	}
+
+	commitsAhead := git.CommitsAhead(rp.AbsPath, defaultBranch, rp.Branch)
+	worktreeStatus := git.StatusPorcelain(rp.AbsPath)
+
+	return statusDescription(defaultBranch, commitsAhead, worktreeStatus), nil
+}
+
 func statusDescription(defaultBranch string, commitsAhead int, porcelain string) string {
 	var parts []string
 
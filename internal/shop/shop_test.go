@@ -1,6 +1,9 @@
 package shop
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestStatusDescription(t *testing.T) {
 	tests := []struct {
@@ -56,3 +59,19 @@ func TestStatusDescription(t *testing.T) {
 		})
 	}
 }
+
+func TestWithEnv(t *testing.T) {
+	vars := []string{"SWEATSHOP_SESSION=repo/feat"}
+
+	got := withEnv(vars, []string{"claude", "-c"})
+	want := []string{"env", "SWEATSHOP_SESSION=repo/feat", "claude", "-c"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("withEnv() = %q, want %q", got, want)
+	}
+
+	got = withEnv(vars, []string{"env", "A=1", "claude"})
+	want = []string{"env", "SWEATSHOP_SESSION=repo/feat", "A=1", "claude"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("withEnv() with existing env = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package shop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/amarbel-llc/sweatshop/internal/executor"
+	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/transcript"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// withRecording returns exec set up to record the session into rp's state
+// dir. Executors that cannot record are returned unchanged with a warning.
+func withRecording(exec executor.Executor, rp worktree.ResolvedPath) (executor.Executor, error) {
+	recorder, ok := exec.(executor.Recorder)
+	if !ok || !transcript.Supported {
+		log.Warn("session recording is not supported here, attaching without it")
+		return exec, nil
+	}
+
+	stateDir, err := worktree.StateDir(rp.AbsPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving state dir: %w", err)
+	}
+
+	path := transcript.NewPath(filepath.Join(stateDir, transcript.DirName))
+	log.Info("recording session", "transcript", path)
+	return recorder.Record(path), nil
+}
+
+// ArchivedTranscriptsDir is where clean moves the transcripts of a removed
+// worktree when the sweatfile sets archive_transcripts.
+func ArchivedTranscriptsDir(repoPath, branch string) string {
+	return filepath.Join(worktree.GlobalStateDir(), transcript.DirName, filepath.Base(repoPath), branch)
+}
+
+// Transcripts returns the recorded sessions of rp, oldest first. Transcripts
+// archived by clean are included, so a removed worktree can still be replayed.
+func Transcripts(rp worktree.ResolvedPath) []string {
+	paths := transcript.List(ArchivedTranscriptsDir(rp.RepoPath, rp.Branch))
+	if gitDir, err := git.GitDir(rp.AbsPath); err == nil {
+		paths = append(paths, transcript.List(filepath.Join(gitDir, worktree.StateDirName, transcript.DirName))...)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+	return paths
+}
+
+// Replay plays back the transcript of rp called name, as listed by
+// Transcripts, or the most recent one when name is empty.
+func Replay(rp worktree.ResolvedPath, name string, opts transcript.ReplayOptions) error {
+	paths := Transcripts(rp)
+	if len(paths) == 0 {
+		return fmt.Errorf("no transcripts recorded for %s", rp.SessionKey)
+	}
+
+	path := paths[len(paths)-1]
+	if name != "" {
+		path = ""
+		for _, p := range paths {
+			if filepath.Base(p) == name || filepath.Base(p) == name+transcript.Ext {
+				path = p
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("transcript %s not found for %s", name, rp.SessionKey)
+		}
+	}
+
+	_, events, err := transcript.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return transcript.Replay(events, os.Stdout, opts)
+}
@@ -0,0 +1,177 @@
+package spawn
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/amarbel-llc/sweatshop/internal/executor"
+	"github.com/amarbel-llc/sweatshop/internal/shop"
+	"github.com/amarbel-llc/sweatshop/internal/tap"
+	"github.com/amarbel-llc/sweatshop/internal/worktree"
+)
+
+// Task is one agent run read from a task file.
+type Task struct {
+	Branch string `toml:"branch"`
+	Prompt string `toml:"prompt"`
+	Agent  string `toml:"agent"`
+	Base   string `toml:"base"`
+}
+
+type taskFile struct {
+	Tasks []Task `toml:"task"`
+}
+
+// Parse reads a task file of [[task]] tables and validates that every task
+// has a unique branch and a prompt. Branches must be bare names, since
+// worktree.ResolvePath takes a name with "/" or "." for a path in the repo.
+func Parse(data []byte) ([]Task, error) {
+	var tf taskFile
+	if err := toml.Unmarshal(data, &tf); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for i, t := range tf.Tasks {
+		if t.Branch == "" {
+			return nil, fmt.Errorf("task %d: missing branch", i+1)
+		}
+		if strings.ContainsAny(t.Branch, "/.") {
+			return nil, fmt.Errorf("task %d: branch %q must be a bare name without \"/\" or \".\"", i+1, t.Branch)
+		}
+		if t.Prompt == "" {
+			return nil, fmt.Errorf("task %d (%s): missing prompt", i+1, t.Branch)
+		}
+		if seen[t.Branch] {
+			return nil, fmt.Errorf("task %d: duplicate branch %q", i+1, t.Branch)
+		}
+		seen[t.Branch] = true
+	}
+
+	return tf.Tasks, nil
+}
+
+func Load(path string) ([]Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+type Options struct {
+	// Jobs bounds how many agents run at once when they run headlessly.
+	Jobs int
+	// Spawner starts each task in its own background session. When nil,
+	// tasks run headlessly and are reported as they finish.
+	Spawner executor.Spawner
+}
+
+// Run creates one worktree per task, one at a time so that git never races
+// with itself, then launches the tasks with at most opts.Jobs running
+// concurrently. Progress is reported to w as a TAP stream.
+func Run(repoPath string, tasks []Task, w io.Writer, opts Options) error {
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	tw := tap.NewWriter(w)
+	var mu sync.Mutex
+	var failed bool
+
+	fail := func(desc string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = true
+		tw.NotOk(desc, map[string]string{
+			"message":  err.Error(),
+			"severity": "fail",
+		})
+	}
+
+	var ready []worktree.ResolvedPath
+	var readyTasks []Task
+	for _, t := range tasks {
+		rp, err := worktree.ResolvePath(repoPath, t.Branch)
+		if err != nil {
+			fail("create "+t.Branch, err)
+			continue
+		}
+		if err := shop.Ensure(rp, shop.CreateOptions{Agent: t.Agent, Base: t.Base}); err != nil {
+			fail("create "+t.Branch, err)
+			continue
+		}
+		tw.Ok("create " + t.Branch)
+		ready = append(ready, rp)
+		readyTasks = append(readyTasks, t)
+	}
+
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	for i := range ready {
+		rp, t := ready[i], readyTasks[i]
+		runOpts := shop.RunOptions{Agent: t.Agent, Prompt: t.Prompt}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if opts.Spawner != nil {
+				if err := shop.Spawn(opts.Spawner, rp, runOpts); err != nil {
+					fail("spawn "+t.Branch, err)
+					return
+				}
+				mu.Lock()
+				tw.OkDiag("spawn "+t.Branch, map[string]string{"session": rp.SessionKey})
+				mu.Unlock()
+				return
+			}
+
+			record, err := shop.RunHeadless(rp, runOpts)
+			if err != nil {
+				fail("run "+t.Branch, err)
+				return
+			}
+
+			desc, err := shop.Describe(&rp)
+			if err != nil {
+				desc = err.Error()
+			}
+			duration := time.Duration(record.Duration * float64(time.Second)).Round(time.Second)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if record.ExitCode != 0 {
+				failed = true
+				tw.NotOk("run "+t.Branch, map[string]string{
+					"message":  fmt.Sprintf("agent exited with status %d after %s", record.ExitCode, duration),
+					"severity": "fail",
+					"log":      record.Log,
+				})
+				return
+			}
+			tw.OkDiag("run "+t.Branch, map[string]string{
+				"describe": desc,
+				"duration": duration.String(),
+			})
+		}()
+	}
+
+	wg.Wait()
+	tw.Plan()
+
+	if failed {
+		return fmt.Errorf("one or more tasks failed")
+	}
+	return nil
+}
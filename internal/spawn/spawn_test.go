@@ -0,0 +1,76 @@
+package spawn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `
+[[task]]
+branch = "fix-login"
+prompt = "Fix the login redirect"
+
+[[task]]
+branch = "bump-deps"
+prompt = "Update go dependencies"
+agent = "codex"
+base = "origin/main"
+`
+	tasks, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+	if tasks[0].Branch != "fix-login" || tasks[0].Agent != "" {
+		t.Errorf("task 0: got %+v", tasks[0])
+	}
+	if tasks[1].Agent != "codex" || tasks[1].Base != "origin/main" {
+		t.Errorf("task 1: got %+v", tasks[1])
+	}
+}
+
+func TestParseRejectsInvalidTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "missing branch",
+			input: "[[task]]\nprompt = \"x\"\n",
+			want:  "missing branch",
+		},
+		{
+			name:  "missing prompt",
+			input: "[[task]]\nbranch = \"a\"\n",
+			want:  "missing prompt",
+		},
+		{
+			name:  "duplicate branch",
+			input: "[[task]]\nbranch = \"a\"\nprompt = \"x\"\n[[task]]\nbranch = \"a\"\nprompt = \"y\"\n",
+			want:  "duplicate branch",
+		},
+		{
+			name:  "branch with slash",
+			input: "[[task]]\nbranch = \"feature/login\"\nprompt = \"x\"\n",
+			want:  "bare name",
+		},
+		{
+			name:  "branch with dot",
+			input: "[[task]]\nbranch = \"fix-1.2\"\nprompt = \"x\"\n",
+			want:  "bare name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,14 +3,19 @@ package status
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 
 	"github.com/amarbel-llc/sweatshop/internal/git"
+	"github.com/amarbel-llc/sweatshop/internal/hook"
+	"github.com/amarbel-llc/sweatshop/internal/ports"
+	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
 	"github.com/amarbel-llc/sweatshop/internal/tap"
 	"github.com/amarbel-llc/sweatshop/internal/worktree"
 )
@@ -22,9 +27,21 @@ type BranchStatus struct {
 	Remote       string
 	LastCommit   string
 	LastModified string
-	IsWorktree   bool
+	// Scratch is the size of the worktree scratch dir, set only once it
+	// passes ScratchWarnSize.
+	Scratch string
+	// Ports lists the worktree's allocated ports, e.g. "web=41230".
+	Ports string
+	// State is what the worktree's agent is doing, e.g. "waiting".
+	State string
+	// Agent tells when the worktree's agent conversation was last active.
+	Agent      string
+	IsWorktree bool
 }
 
+// ScratchWarnSize is the scratch dir size from which status reports it.
+const ScratchWarnSize = 100 << 20
+
 func CollectBranchStatus(repoLabel, branchPath, branchName string) BranchStatus {
 	bs := BranchStatus{
 		Repo:   repoLabel,
@@ -64,9 +81,65 @@ func CollectBranchStatus(repoLabel, branchPath, branchName string) BranchStatus
 		bs.LastModified = "n/a"
 	}
 
+	if size := dirSize(filepath.Join(branchPath, sweatfile.ScratchDirName)); size >= ScratchWarnSize {
+		bs.Scratch = formatSize(size)
+	}
+
+	if allocation, ok := ports.Lookup(branchPath); ok {
+		bs.Ports = allocation.String()
+	}
+
+	if state, ok := hook.LoadState(branchPath); ok {
+		bs.State = state.Activity
+		bs.Agent = ago(time.Since(state.LastActive))
+	}
+
 	return bs
 }
 
+// ago renders d as a coarse age, e.g. "5m ago".
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func parseDirtyStatus(porcelain string) string {
 	lines := strings.Split(porcelain, "\n")
 
@@ -145,9 +218,42 @@ func (bs BranchStatus) isClean() bool {
 	return bs.Dirty == "clean" && (strings.HasPrefix(bs.Remote, "≡") || bs.Remote == "")
 }
 
-func renderTable(data [][]string) string {
+// optionalColumns are appended to the table only when some row has a value.
+var optionalColumns = []struct {
+	header string
+	value  func(BranchStatus) string
+}{
+	{"State", func(bs BranchStatus) string { return bs.State }},
+	{"Agent", func(bs BranchStatus) string { return bs.Agent }},
+	{"Ports", func(bs BranchStatus) string { return bs.Ports }},
+	{"Scratch", func(bs BranchStatus) string { return bs.Scratch }},
+}
+
+func renderTable(rows []BranchStatus) string {
 	headers := []string{"Repo", "Branch", "Status", "Remote", "Commit", "Modified"}
 
+	data := make([][]string, len(rows))
+	for i, r := range rows {
+		data[i] = []string{r.Repo, r.Branch, r.Dirty, r.Remote, r.LastCommit, r.LastModified}
+	}
+
+	for _, col := range optionalColumns {
+		var values []string
+		var shown bool
+		for _, r := range rows {
+			v := col.value(r)
+			shown = shown || v != ""
+			values = append(values, v)
+		}
+		if !shown {
+			continue
+		}
+		headers = append(headers, col.header)
+		for i, v := range values {
+			data[i] = append(data[i], v)
+		}
+	}
+
 	t := table.New().
 		Border(lipgloss.RoundedBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("15"))).
@@ -178,6 +284,15 @@ func renderTable(data [][]string) string {
 				return base.Foreground(lipgloss.Color("8"))
 			}
 
+			if headers[col] == "State" {
+				switch data[row][col] {
+				case hook.Waiting:
+					return base.Foreground(lipgloss.Color("3")).Bold(true)
+				case hook.Working:
+					return base.Foreground(lipgloss.Color("2"))
+				}
+			}
+
 			return base
 		})
 
@@ -190,16 +305,15 @@ var (
 )
 
 func Render(rows []BranchStatus) string {
-	var repoRows, worktreeRows, cleanRows [][]string
+	var repoRows, worktreeRows, cleanRows []BranchStatus
 
 	for _, r := range rows {
-		row := []string{r.Repo, r.Branch, r.Dirty, r.Remote, r.LastCommit, r.LastModified}
 		if r.isClean() {
-			cleanRows = append(cleanRows, row)
+			cleanRows = append(cleanRows, r)
 		} else if r.IsWorktree {
-			worktreeRows = append(worktreeRows, row)
+			worktreeRows = append(worktreeRows, r)
 		} else {
-			repoRows = append(repoRows, row)
+			repoRows = append(repoRows, r)
 		}
 	}
 
@@ -3,6 +3,7 @@ package status
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseDirtyStatusClean(t *testing.T) {
@@ -142,3 +143,59 @@ func TestRenderCleanGrouping(t *testing.T) {
 		t.Error("expected 'repo-a' in clean section")
 	}
 }
+
+func TestRenderScratchColumnOnlyWhenSet(t *testing.T) {
+	rows := []BranchStatus{
+		{Repo: "repo", Branch: "feature", Dirty: "1M", IsWorktree: true},
+	}
+	if strings.Contains(Render(rows), "Scratch") {
+		t.Error("did not expect 'Scratch' column when no row has a large scratch dir")
+	}
+
+	rows[0].Scratch = "150.0MiB"
+	output := Render(rows)
+	if !strings.Contains(output, "Scratch") || !strings.Contains(output, "150.0MiB") {
+		t.Errorf("expected scratch column in output:\n%s", output)
+	}
+}
+
+func TestRenderStateColumn(t *testing.T) {
+	rows := []BranchStatus{
+		{Repo: "repo", Branch: "a", Dirty: "1M", IsWorktree: true, State: "waiting", Agent: "5m ago"},
+		{Repo: "repo", Branch: "b", Dirty: "1M", IsWorktree: true},
+	}
+	output := Render(rows)
+	for _, want := range []string{"State", "waiting", "Agent", "5m ago"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := map[int64]string{
+		512:       "512B",
+		2048:      "2.0KiB",
+		150 << 20: "150.0MiB",
+		3 << 30:   "3.0GiB",
+	}
+	for n, want := range cases {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestAgo(t *testing.T) {
+	cases := map[time.Duration]string{
+		10 * time.Second: "just now",
+		5 * time.Minute:  "5m ago",
+		3 * time.Hour:    "3h ago",
+		50 * time.Hour:   "2d ago",
+	}
+	for d, want := range cases {
+		if got := ago(d); got != want {
+			t.Errorf("ago(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
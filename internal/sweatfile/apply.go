@@ -9,11 +9,19 @@ import (
 	"github.com/amarbel-llc/sweatshop/internal/git"
 )
 
+// ScratchDirName is the per-worktree temp directory exported to sessions as
+// TMPDIR and SWEATSHOP_TMP.
+const ScratchDirName = ".sweatshop-tmp"
+
 // HardcodedExcludes are always written to .git/info/exclude regardless of sweatfile config.
 var HardcodedExcludes = []string{
 	".claude",
+	ScratchDirName,
 }
 
+// Apply writes agent-independent sweatfile settings into a worktree. Claude
+// settings are applied separately via ApplyClaudeSettings, only for agents
+// that need them.
 func Apply(worktreePath string, sf Sweatfile) error {
 	allExcludes := append(sf.GitExcludes, HardcodedExcludes...)
 	if len(allExcludes) > 0 {
@@ -26,8 +34,8 @@ func Apply(worktreePath string, sf Sweatfile) error {
 		}
 	}
 
-	if err := ApplyClaudeSettings(worktreePath, sf.ClaudeAllow); err != nil {
-		return fmt.Errorf("applying claude settings: %w", err)
+	if err := os.MkdirAll(filepath.Join(worktreePath, ScratchDirName), 0o755); err != nil {
+		return fmt.Errorf("creating scratch directory: %w", err)
 	}
 
 	return nil
@@ -61,6 +69,20 @@ func applyGitExcludes(excludePath string, patterns []string) error {
 	return nil
 }
 
+// ManagedClaudeRules returns the allow list ApplyClaudeSettings writes for a
+// worktree: the sweatfile's claude_allow rules plus access to the worktree
+// and its scratch dir.
+func ManagedClaudeRules(worktreePath string, rules []string) []string {
+	scratchPath := filepath.Join(worktreePath, ScratchDirName)
+
+	allRules := append([]string{}, rules...)
+	return append(allRules,
+		"Edit(//"+worktreePath+"/**)",
+		"Write(//"+worktreePath+"/**)",
+		"Read(//"+scratchPath+"/**)",
+	)
+}
+
 func ApplyClaudeSettings(worktreePath string, rules []string) error {
 	settingsPath := filepath.Join(worktreePath, ".claude", "settings.local.json")
 
@@ -77,14 +99,8 @@ func ApplyClaudeSettings(worktreePath string, rules []string) error {
 		permsMap = make(map[string]any)
 	}
 
-	allRules := append([]string{}, rules...)
-	allRules = append(allRules,
-		"Edit(//"+worktreePath+"/**)",
-		"Write(//"+worktreePath+"/**)",
-	)
-
 	permsMap["defaultMode"] = "acceptEdits"
-	permsMap["allow"] = allRules
+	permsMap["allow"] = ManagedClaudeRules(worktreePath, rules)
 	doc["permissions"] = permsMap
 
 	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
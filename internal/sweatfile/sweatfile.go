@@ -11,8 +11,57 @@ import (
 )
 
 type Sweatfile struct {
-	GitExcludes []string `toml:"git_excludes"`
-	ClaudeAllow []string `toml:"claude_allow"`
+	GitExcludes    []string `toml:"git_excludes"`
+	ClaudeAllow    []string `toml:"claude_allow"`
+	DevEnv         string   `toml:"devenv"`
+	DevEnvPrebuild *bool    `toml:"devenv_prebuild"`
+	Direnv         string   `toml:"direnv"`
+	// Record captures attached sessions as transcripts in the worktree
+	// state dir. ArchiveTranscripts keeps them when clean removes the
+	// worktree.
+	Record             *bool            `toml:"record"`
+	ArchiveTranscripts *bool            `toml:"archive_transcripts"`
+	Agent              Agent            `toml:"agent"`
+	Agents             map[string]Agent `toml:"agents"`
+	// Ports maps a name to how many consecutive ports each worktree gets,
+	// exported as PORT_<NAME>. A count of 0 drops an inherited name.
+	Ports map[string]int `toml:"ports"`
+	// Notify is a shell command run when an agent starts waiting for a
+	// permission, with SWEATSHOP_SESSION and SWEATSHOP_MESSAGE set.
+	Notify string `toml:"notify"`
+}
+
+// Agent configures the command launched by attach. Args and Env values are
+// Go templates rendered against the session (see internal/agent).
+type Agent struct {
+	Command string            `toml:"command"`
+	Args    []string          `toml:"args"`
+	Env     map[string]string `toml:"env"`
+	// PromptArgs are appended for headless runs; {{.Prompt}} is the prompt.
+	PromptArgs []string `toml:"prompt_args"`
+	// ResumeArgs are appended by attach --resume; {{.SessionID}} is the
+	// agent's last recorded conversation in the worktree.
+	ResumeArgs []string `toml:"resume_args"`
+	// Claude marks the agent as needing Claude Code workspace trust and
+	// settings. When unset it defaults to true for the claude command.
+	Claude *bool `toml:"claude"`
+}
+
+// RecordSessions reports whether attached sessions should be recorded.
+func (sf Sweatfile) RecordSessions() bool {
+	return sf.Record != nil && *sf.Record
+}
+
+// KeepTranscripts reports whether clean should archive transcripts rather
+// than delete them with the worktree.
+func (sf Sweatfile) KeepTranscripts() bool {
+	return sf.ArchiveTranscripts != nil && *sf.ArchiveTranscripts
+}
+
+// PrebuildDevEnv reports whether the dev environment should be built when a
+// worktree is created.
+func (sf Sweatfile) PrebuildDevEnv() bool {
+	return sf.DevEnvPrebuild != nil && *sf.DevEnvPrebuild
 }
 
 func Parse(data []byte) (Sweatfile, error) {
@@ -53,6 +102,88 @@ func Merge(base, repo Sweatfile) Sweatfile {
 		}
 	}
 
+	// Scalars: zero value = inherit, anything else = override
+	if repo.DevEnv != "" {
+		merged.DevEnv = repo.DevEnv
+	}
+	if repo.DevEnvPrebuild != nil {
+		merged.DevEnvPrebuild = repo.DevEnvPrebuild
+	}
+	if repo.Direnv != "" {
+		merged.Direnv = repo.Direnv
+	}
+	if repo.Notify != "" {
+		merged.Notify = repo.Notify
+	}
+	if repo.Record != nil {
+		merged.Record = repo.Record
+	}
+	if repo.ArchiveTranscripts != nil {
+		merged.ArchiveTranscripts = repo.ArchiveTranscripts
+	}
+
+	merged.Agent = mergeAgent(base.Agent, repo.Agent)
+	if repo.Agents != nil {
+		agents := make(map[string]Agent, len(base.Agents)+len(repo.Agents))
+		for name, a := range base.Agents {
+			agents[name] = a
+		}
+		for name, a := range repo.Agents {
+			agents[name] = mergeAgent(agents[name], a)
+		}
+		merged.Agents = agents
+	}
+
+	if repo.Ports != nil {
+		ports := make(map[string]int, len(base.Ports)+len(repo.Ports))
+		for name, n := range base.Ports {
+			ports[name] = n
+		}
+		for name, n := range repo.Ports {
+			ports[name] = n
+		}
+		merged.Ports = ports
+	}
+
+	return merged
+}
+
+// mergeAgent layers repo over base. A repo agent with a different command
+// replaces base entirely, since args and env rarely carry across commands.
+// Otherwise args follow the array rules above and env merges per key.
+func mergeAgent(base, repo Agent) Agent {
+	if repo.Command != "" && repo.Command != base.Command {
+		return repo
+	}
+
+	merged := base
+	if repo.Args != nil {
+		if len(repo.Args) == 0 {
+			merged.Args = []string{}
+		} else {
+			merged.Args = append(append([]string{}, base.Args...), repo.Args...)
+		}
+	}
+	if repo.Env != nil {
+		env := make(map[string]string, len(base.Env)+len(repo.Env))
+		for k, v := range base.Env {
+			env[k] = v
+		}
+		for k, v := range repo.Env {
+			env[k] = v
+		}
+		merged.Env = env
+	}
+	if repo.PromptArgs != nil {
+		merged.PromptArgs = repo.PromptArgs
+	}
+	if repo.ResumeArgs != nil {
+		merged.ResumeArgs = repo.ResumeArgs
+	}
+	if repo.Claude != nil {
+		merged.Claude = repo.Claude
+	}
+
 	return merged
 }
 
@@ -358,3 +358,149 @@ claude_allow = []
 		t.Errorf("expected empty ClaudeAllow (cleared by repo), got %v", result.Merged.ClaudeAllow)
 	}
 }
+
+func TestParseDevEnv(t *testing.T) {
+	input := `
+devenv = "mise"
+devenv_prebuild = true
+direnv = "allow"
+`
+	sf, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sf.DevEnv != "mise" {
+		t.Errorf("devenv: got %q", sf.DevEnv)
+	}
+	if !sf.PrebuildDevEnv() {
+		t.Error("expected devenv_prebuild to be true")
+	}
+	if sf.Direnv != "allow" {
+		t.Errorf("direnv: got %q", sf.Direnv)
+	}
+}
+
+func TestMergeDevEnvOverrides(t *testing.T) {
+	on, off := true, false
+	base := Sweatfile{DevEnv: "flake", DevEnvPrebuild: &on, Direnv: "exec"}
+
+	merged := Merge(base, Sweatfile{})
+	if merged.DevEnv != "flake" || !merged.PrebuildDevEnv() || merged.Direnv != "exec" {
+		t.Errorf("expected inherited devenv settings, got %q %v %q", merged.DevEnv, merged.PrebuildDevEnv(), merged.Direnv)
+	}
+
+	merged = Merge(base, Sweatfile{DevEnv: "off", DevEnvPrebuild: &off, Direnv: "off"})
+	if merged.DevEnv != "off" {
+		t.Errorf("expected repo devenv to override, got %q", merged.DevEnv)
+	}
+	if merged.Direnv != "off" {
+		t.Errorf("expected repo direnv to override, got %q", merged.Direnv)
+	}
+	if merged.PrebuildDevEnv() {
+		t.Error("expected repo devenv_prebuild=false to override")
+	}
+}
+
+func TestParseAgent(t *testing.T) {
+	input := `
+[agent]
+command = "claude"
+args = ["--model", "opus"]
+env = { SESSION = "{{.SessionKey}}" }
+
+[agents.codex]
+command = "codex"
+claude = false
+`
+	sf, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sf.Agent.Command != "claude" || len(sf.Agent.Args) != 2 {
+		t.Errorf("agent: got %+v", sf.Agent)
+	}
+	if sf.Agent.Env["SESSION"] != "{{.SessionKey}}" {
+		t.Errorf("agent env: got %v", sf.Agent.Env)
+	}
+	codex, ok := sf.Agents["codex"]
+	if !ok || codex.Command != "codex" || codex.Claude == nil || *codex.Claude {
+		t.Errorf("agents.codex: got %+v", codex)
+	}
+}
+
+func TestMergeAgent(t *testing.T) {
+	base := Sweatfile{
+		Agent: Agent{Command: "claude", Args: []string{"--model", "opus"}, Env: map[string]string{"A": "1"}},
+		Agents: map[string]Agent{
+			"codex": {Command: "codex"},
+		},
+	}
+
+	merged := Merge(base, Sweatfile{
+		Agent: Agent{Args: []string{"--verbose"}, Env: map[string]string{"B": "2"}},
+	})
+	if merged.Agent.Command != "claude" || len(merged.Agent.Args) != 3 {
+		t.Errorf("expected appended args on same command, got %+v", merged.Agent)
+	}
+	if merged.Agent.Env["A"] != "1" || merged.Agent.Env["B"] != "2" {
+		t.Errorf("expected merged env, got %v", merged.Agent.Env)
+	}
+
+	merged = Merge(base, Sweatfile{Agent: Agent{Command: "aider"}})
+	if merged.Agent.Command != "aider" || merged.Agent.Args != nil || merged.Agent.Env != nil {
+		t.Errorf("expected different command to replace agent, got %+v", merged.Agent)
+	}
+
+	merged = Merge(base, Sweatfile{Agents: map[string]Agent{"wrapper": {Command: "./agent.sh"}}})
+	if len(merged.Agents) != 2 {
+		t.Errorf("expected profiles to merge by name, got %v", merged.Agents)
+	}
+}
+
+func TestMergePorts(t *testing.T) {
+	sf, err := Parse([]byte("[ports]\nweb = 1\ndb = 2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sf.Ports["web"] != 1 || sf.Ports["db"] != 2 {
+		t.Fatalf("unexpected ports %v", sf.Ports)
+	}
+
+	merged := Merge(sf, Sweatfile{})
+	if len(merged.Ports) != 2 {
+		t.Errorf("expected inherited ports, got %v", merged.Ports)
+	}
+
+	merged = Merge(sf, Sweatfile{Ports: map[string]int{"db": 0, "api": 1}})
+	want := map[string]int{"web": 1, "db": 0, "api": 1}
+	if len(merged.Ports) != len(want) {
+		t.Fatalf("expected %v, got %v", want, merged.Ports)
+	}
+	for name, n := range want {
+		if merged.Ports[name] != n {
+			t.Errorf("ports[%s] = %d, want %d", name, merged.Ports[name], n)
+		}
+	}
+}
+
+func TestMergeRecordAndNotify(t *testing.T) {
+	sf, err := Parse([]byte("record = true\narchive_transcripts = true\nnotify = \"notify-send waiting\"\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sf.RecordSessions() || !sf.KeepTranscripts() {
+		t.Fatalf("expected record and archive_transcripts, got %+v", sf)
+	}
+
+	off := false
+	merged := Merge(sf, Sweatfile{Record: &off})
+	if merged.RecordSessions() {
+		t.Error("expected repo record=false to override")
+	}
+	if !merged.KeepTranscripts() {
+		t.Error("expected archive_transcripts to be inherited")
+	}
+	if merged.Notify != "notify-send waiting" {
+		t.Errorf("expected notify to be inherited, got %q", merged.Notify)
+	}
+}
@@ -23,34 +23,48 @@ func (tw *Writer) Ok(description string) int {
 	return tw.n
 }
 
+// OkDiag is Ok followed by a YAML diagnostics block, for details such as a
+// session name that must not go in the description, where # starts a directive.
+func (tw *Writer) OkDiag(description string, diagnostics map[string]string) int {
+	tw.n++
+	fmt.Fprintf(tw.w, "ok %d - %s\n", tw.n, description)
+	tw.diagnostics(diagnostics)
+	return tw.n
+}
+
 func (tw *Writer) NotOk(description string, diagnostics map[string]string) int {
 	tw.n++
 	fmt.Fprintf(tw.w, "not ok %d - %s\n", tw.n, description)
-	if len(diagnostics) > 0 {
-		fmt.Fprintln(tw.w, "  ---")
-		keys := make([]string, 0, len(diagnostics))
-		for k := range diagnostics {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			v := diagnostics[k]
-			if strings.Contains(v, "\n") {
-				fmt.Fprintf(tw.w, "  %s: |\n", k)
-				lines := strings.Split(v, "\n")
-				for len(lines) > 0 && lines[len(lines)-1] == "" {
-					lines = lines[:len(lines)-1]
-				}
-				for _, line := range lines {
-					fmt.Fprintf(tw.w, "    %s\n", line)
-				}
-			} else {
-				fmt.Fprintf(tw.w, "  %s: %s\n", k, v)
+	tw.diagnostics(diagnostics)
+	return tw.n
+}
+
+func (tw *Writer) diagnostics(diagnostics map[string]string) {
+	if len(diagnostics) == 0 {
+		return
+	}
+	fmt.Fprintln(tw.w, "  ---")
+	keys := make([]string, 0, len(diagnostics))
+	for k := range diagnostics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := diagnostics[k]
+		if strings.Contains(v, "\n") {
+			fmt.Fprintf(tw.w, "  %s: |\n", k)
+			lines := strings.Split(v, "\n")
+			for len(lines) > 0 && lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			for _, line := range lines {
+				fmt.Fprintf(tw.w, "    %s\n", line)
 			}
+		} else {
+			fmt.Fprintf(tw.w, "  %s: %s\n", k, v)
 		}
-		fmt.Fprintln(tw.w, "  ...")
 	}
-	return tw.n
+	fmt.Fprintln(tw.w, "  ...")
 }
 
 func (tw *Writer) Skip(description, reason string) int {
@@ -59,6 +59,16 @@ func TestNotOkEmitsLine(t *testing.T) {
 	}
 }
 
+func TestOkDiagEmitsDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	tw.OkDiag("spawn feature", map[string]string{"session": "repo/feature"})
+	want := "TAP version 14\nok 1 - spawn feature\n  ---\n  session: repo/feature\n  ...\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
 func TestNotOkWithDiagnostics(t *testing.T) {
 	var buf bytes.Buffer
 	tw := NewWriter(&buf)
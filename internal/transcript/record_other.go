@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package transcript
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Supported reports whether sessions can be recorded on this platform.
+const Supported = false
+
+// Record is not available without a PTY implementation for this platform.
+func Record(path string, cmd *exec.Cmd) error {
+	return errors.New("session recording is not supported on this platform")
+}
@@ -0,0 +1,162 @@
+//go:build linux || darwin
+
+package transcript
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/sys/unix"
+)
+
+// Supported reports whether sessions can be recorded on this platform.
+const Supported = true
+
+// Record runs cmd on a new PTY, relaying it to and from the current terminal
+// and writing everything it outputs to an asciicast transcript at path.
+// cmd's Stdin, Stdout and Stderr are replaced by the PTY.
+func Record(path string, cmd *exec.Cmd) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+
+	stdin := os.Stdin.Fd()
+	isTerm := term.IsTerminal(stdin)
+	width, height := 80, 24
+	if isTerm {
+		if w, h, err := term.GetSize(stdin); err == nil {
+			width, height = w, h
+		}
+	}
+	setSize(master, width, height)
+
+	tw, err := NewWriter(f, Header{
+		Width:   width,
+		Height:  height,
+		Command: strings.Join(cmd.Args, " "),
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		},
+	})
+	if err != nil {
+		slave.Close()
+		return err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		slave.Close()
+		return err
+	}
+	slave.Close()
+
+	if isTerm {
+		if state, err := term.MakeRaw(stdin); err == nil {
+			defer term.Restore(stdin, state)
+		}
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(winch)
+		close(winch)
+	}()
+	go func() {
+		for range winch {
+			if w, h, err := term.GetSize(stdin); err == nil {
+				setSize(master, w, h)
+				tw.Resize(w, h)
+			}
+		}
+	}()
+
+	stopR, stopW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer stopR.Close()
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		copyInput(master, int(stdin), stopR)
+	}()
+
+	// Reads from the master fail with EIO once every process holding the
+	// slave side has exited, which ends the copy.
+	io.Copy(io.MultiWriter(os.Stdout, outputWriter{tw}), master)
+	stopW.Close()
+	<-inputDone
+
+	waitErr := cmd.Wait()
+	if err := tw.Close(); err != nil && waitErr == nil {
+		return err
+	}
+	return waitErr
+}
+
+// copyInput relays stdin to dst until stop becomes readable, which closing
+// its write end does. It waits for either rather than blocking in a read of
+// stdin, which would outlive the session and swallow the next input meant
+// for whatever runs after it.
+func copyInput(dst io.Writer, stdin int, stop *os.File) {
+	stopFd := int(stop.Fd())
+	buf := make([]byte, 32*1024)
+	for {
+		var fds unix.FdSet
+		fds.Set(stdin)
+		fds.Set(stopFd)
+		if _, err := unix.Select(max(stdin, stopFd)+1, &fds, nil, nil, nil); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return
+		}
+		if fds.IsSet(stopFd) {
+			return
+		}
+
+		n, err := unix.Read(stdin, buf)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		}
+		if err != nil || n == 0 {
+			return
+		}
+		if _, err := dst.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+func setSize(pty *os.File, width, height int) {
+	unix.IoctlSetWinsize(int(pty.Fd()), unix.TIOCSWINSZ, &unix.Winsize{
+		Col: uint16(width),
+		Row: uint16(height),
+	})
+}
+
+type outputWriter struct{ tw *Writer }
+
+func (o outputWriter) Write(p []byte) (int, error) {
+	return len(p), o.tw.Output(p)
+}
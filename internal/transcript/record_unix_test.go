@@ -0,0 +1,55 @@
+//go:build linux || darwin
+
+package transcript
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCopyInputStops(t *testing.T) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inR.Close()
+	defer inW.Close()
+	stopR, stopW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopR.Close()
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outR.Close()
+	defer outW.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyInput(outW, int(inR.Fd()), stopR)
+	}()
+
+	buf := make([]byte, 16)
+	inW.Write([]byte("ls\n"))
+	if n, _ := outR.Read(buf); string(buf[:n]) != "ls\n" {
+		t.Errorf("got %q, want %q", buf[:n], "ls\n")
+	}
+	stopW.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected copyInput to return once stopped")
+	}
+
+	// Input after the session belongs to whatever reads stdin next.
+	inW.Write([]byte("exit\n"))
+	if n, _ := inR.Read(buf); string(buf[:n]) != "exit\n" {
+		t.Errorf("expected later input to be left unread, got %q", buf[:n])
+	}
+}
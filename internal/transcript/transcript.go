@@ -0,0 +1,225 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DirName holds session transcripts inside the worktree state dir.
+const DirName = "transcripts"
+
+// Ext is the extension of transcript files, which are asciicast v2.
+const Ext = ".cast"
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is one line after the header: seconds since the start, the event
+// type ("o" for output, "r" for a resize to "COLSxROWS") and its data.
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.Time, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &e.Data)
+}
+
+// Writer appends events to an asciicast stream. It is safe for concurrent
+// use, since output and resizes arrive from different goroutines.
+type Writer struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	enc   *json.Encoder
+	start time.Time
+	// pending holds the start of a UTF-8 sequence split across reads, which
+	// JSON encoding would otherwise replace with U+FFFD.
+	pending []byte
+}
+
+// NewWriter writes header to w and returns a Writer for the events that
+// follow. Event times are measured from now.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.Version = 2
+	now := time.Now()
+	if header.Timestamp == 0 {
+		header.Timestamp = now.Unix()
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, enc: enc, start: now}, nil
+}
+
+// Output records p as terminal output.
+func (t *Writer) Output(p []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data := append(t.pending, p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	t.pending = append([]byte(nil), data[cut:]...)
+
+	if cut == 0 {
+		return nil
+	}
+	return t.event("o", string(data[:cut]))
+}
+
+// Resize records a terminal size change.
+func (t *Writer) Resize(cols, rows int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (t *Writer) event(typ, data string) error {
+	return t.enc.Encode(Event{
+		Time: time.Since(t.start).Seconds(),
+		Type: typ,
+		Data: data,
+	})
+}
+
+// Close records any incomplete trailing output and flushes the stream.
+func (t *Writer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) > 0 {
+		if err := t.event("o", string(t.pending)); err != nil {
+			return err
+		}
+		t.pending = nil
+	}
+	return t.w.Flush()
+}
+
+// Read parses an asciicast v2 stream.
+func Read(r io.Reader) (Header, []Event, error) {
+	dec := json.NewDecoder(r)
+
+	var header Header
+	if err := dec.Decode(&header); err != nil {
+		return Header{}, nil, fmt.Errorf("reading header: %w", err)
+	}
+	if header.Version != 2 {
+		return Header{}, nil, fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+
+	var events []Event
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return Header{}, nil, fmt.Errorf("reading event %d: %w", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+	return header, events, nil
+}
+
+type ReplayOptions struct {
+	// Speed divides the delay between events; 2 plays twice as fast. Zero
+	// or less is treated as 1.
+	Speed float64
+	// MaxIdle caps the delay between two events, so long pauses do not
+	// stall playback. Zero keeps the original delays.
+	MaxIdle time.Duration
+}
+
+// Replay writes the output events to w with their original timing, adjusted
+// by opts. Resize events are skipped, since w is the caller's terminal.
+func Replay(events []Event, w io.Writer, opts ReplayOptions) error {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var last float64
+	for _, e := range events {
+		delay := time.Duration((e.Time - last) / speed * float64(time.Second))
+		last = e.Time
+		if opts.MaxIdle > 0 && delay > opts.MaxIdle {
+			delay = opts.MaxIdle
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+
+		if e.Type != "o" {
+			continue
+		}
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewPath returns a path for a new transcript in dir, named after the
+// current time.
+func NewPath(dir string) string {
+	return filepath.Join(dir, time.Now().Format("20060102T150405.000")+Ext)
+}
+
+// List returns the transcripts in dir, oldest first.
+func List(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+Ext))
+	// Names are timestamps, so lexical order is chronological.
+	sort.Strings(matches)
+	return matches
+}
+
+// ReadFile parses the transcript at path.
+func ReadFile(path string) (Header, []Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
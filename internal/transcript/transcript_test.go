@@ -0,0 +1,91 @@
+package transcript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 100, Height: 30, Command: "claude"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Output([]byte("hello\r\n"))
+	w.Resize(120, 40)
+	w.Output([]byte("\x1b[1mbold\x1b[0m"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header, events, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Command != "claude" {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %v", len(events), events)
+	}
+	if events[0].Type != "o" || events[0].Data != "hello\r\n" {
+		t.Errorf("event 0 = %+v", events[0])
+	}
+	if events[1].Type != "r" || events[1].Data != "120x40" {
+		t.Errorf("event 1 = %+v", events[1])
+	}
+	if events[2].Data != "\x1b[1mbold\x1b[0m" {
+		t.Errorf("event 2 = %+v", events[2])
+	}
+}
+
+func TestOutputSplitRune(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, Header{})
+
+	// "é" is 0xC3 0xA9; split it across two reads.
+	w.Output([]byte("caf\xc3"))
+	w.Output([]byte("\xa9!"))
+	w.Close()
+
+	_, events, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got strings.Builder
+	for _, e := range events {
+		got.WriteString(e.Data)
+	}
+	if got.String() != "café!" {
+		t.Errorf("expected split rune to survive, got %q", got.String())
+	}
+}
+
+func TestReadRejectsOtherVersions(t *testing.T) {
+	if _, _, err := Read(strings.NewReader(`{"version": 1}`)); err == nil {
+		t.Error("expected error for asciicast v1")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	events := []Event{
+		{Time: 0, Type: "o", Data: "a"},
+		{Time: 0.01, Type: "r", Data: "80x24"},
+		{Time: 10, Type: "o", Data: "b"},
+	}
+
+	var out bytes.Buffer
+	start := time.Now()
+	err := Replay(events, &out, ReplayOptions{Speed: 2, MaxIdle: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected max idle to cap the 10s pause, took %s", elapsed)
+	}
+	if out.String() != "ab" {
+		t.Errorf("expected only output events, got %q", out.String())
+	}
+}
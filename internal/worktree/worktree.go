@@ -7,13 +7,19 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/amarbel-llc/sweatshop/internal/agent"
 	"github.com/amarbel-llc/sweatshop/internal/claude"
+	"github.com/amarbel-llc/sweatshop/internal/devenv"
 	"github.com/amarbel-llc/sweatshop/internal/git"
 	"github.com/amarbel-llc/sweatshop/internal/sweatfile"
 )
 
 const WorktreesDir = ".worktrees"
 
+// StateDirName is the directory inside a worktree's git admin dir where
+// sweatshop keeps per-worktree state such as run logs.
+const StateDirName = "sweatshop"
+
 type ResolvedPath struct {
 	AbsPath    string // absolute filesystem path to the worktree
 	RepoPath   string // absolute path to the parent git repo
@@ -74,29 +80,80 @@ func DetectRepo(dir string) (string, error) {
 	}
 }
 
+type CreateOptions struct {
+	// Agent names the sweatfile agent profile the worktree is set up for.
+	// Empty selects the default agent.
+	Agent string
+	// Branch is the branch checked out in the worktree, usually
+	// ResolvedPath.Branch. Empty uses the worktree directory's name.
+	Branch string
+	// Base is the ref the new branch starts from. Empty uses the repo's HEAD.
+	Base string
+}
+
 // Create creates a new git worktree and applies sweatfile configuration.
-func Create(repoPath, worktreePath string) (sweatfile.LoadResult, error) {
+// Claude workspace trust and settings are only written when the selected
+// agent declares it needs them.
+func Create(repoPath, worktreePath string, opts CreateOptions) (sweatfile.LoadResult, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return sweatfile.LoadResult{}, fmt.Errorf("getting home directory: %w", err)
+	}
+
+	result, err := sweatfile.LoadHierarchy(home, repoPath)
+	if err != nil {
+		return sweatfile.LoadResult{}, fmt.Errorf("loading sweatfile: %w", err)
+	}
+
+	a, err := agent.Resolve(result.Merged, opts.Agent)
+	if err != nil {
+		return sweatfile.LoadResult{}, err
+	}
+	if err := devenv.CheckDirenvMode(result.Merged.Direnv); err != nil {
+		return sweatfile.LoadResult{}, err
+	}
+
 	if err := os.MkdirAll(worktreePath, 0o755); err != nil {
 		return sweatfile.LoadResult{}, fmt.Errorf("creating worktree directory: %w", err)
 	}
-	if err := git.RunPassthrough(repoPath, "worktree", "add", worktreePath); err != nil {
+	branch := opts.Branch
+	if branch == "" {
+		branch = filepath.Base(worktreePath)
+	}
+	_, revErr := git.RevParse(repoPath, "refs/heads/"+branch)
+	exists := revErr == nil
+	addArgs, err := worktreeAddArgs(worktreePath, branch, opts.Base, exists)
+	if err != nil {
+		return sweatfile.LoadResult{}, err
+	}
+	if err := git.RunPassthrough(repoPath, addArgs...); err != nil {
 		return sweatfile.LoadResult{}, fmt.Errorf("git worktree add: %w", err)
 	}
 	if err := excludeWorktreesDir(repoPath); err != nil {
 		return sweatfile.LoadResult{}, fmt.Errorf("excluding .worktrees from git: %w", err)
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return sweatfile.LoadResult{}, fmt.Errorf("getting home directory: %w", err)
+	if err := sweatfile.Apply(worktreePath, result.Merged); err != nil {
+		return sweatfile.LoadResult{}, err
 	}
 
-	result, err := sweatfile.LoadHierarchy(home, repoPath)
-	if err != nil {
-		return sweatfile.LoadResult{}, fmt.Errorf("loading sweatfile: %w", err)
+	// direnv exec refuses a blocked .envrc, so exec implies allow.
+	if mode := result.Merged.Direnv; mode == devenv.DirenvAllow || mode == devenv.DirenvExec {
+		if err := devenv.AllowDirenv(worktreePath); err != nil {
+			return sweatfile.LoadResult{}, err
+		}
 	}
-	if err := sweatfile.Apply(worktreePath, result.Merged); err != nil {
-		return sweatfile.LoadResult{}, err
+
+	if !a.Claude {
+		return result, nil
+	}
+
+	if err := sweatfile.ApplyClaudeSettings(worktreePath, result.Merged.ClaudeAllow); err != nil {
+		return sweatfile.LoadResult{}, fmt.Errorf("applying claude settings: %w", err)
+	}
+	settingsPath := filepath.Join(worktreePath, ".claude", "settings.local.json")
+	if err := claude.InstallHooks(settingsPath, claude.SessionHooks()); err != nil {
+		return sweatfile.LoadResult{}, fmt.Errorf("installing claude hooks: %w", err)
 	}
 
 	claudeJSONPath := filepath.Join(home, ".claude.json")
@@ -107,6 +164,23 @@ func Create(repoPath, worktreePath string) (sweatfile.LoadResult, error) {
 	return result, nil
 }
 
+// worktreeAddArgs returns the git worktree add arguments that check out
+// branch at worktreePath, creating it from base, or from HEAD when base is
+// empty, unless it exists. An existing branch is never reset to base.
+func worktreeAddArgs(worktreePath, branch, base string, exists bool) ([]string, error) {
+	if exists {
+		if base != "" {
+			return nil, fmt.Errorf("branch %q already exists, so it cannot start from %s", branch, base)
+		}
+		return []string{"worktree", "add", worktreePath, branch}, nil
+	}
+	args := []string{"worktree", "add", "-b", branch, worktreePath}
+	if base != "" {
+		args = append(args, base)
+	}
+	return args, nil
+}
+
 // excludeWorktreesDir appends .worktrees to .git/info/exclude if not already present.
 func excludeWorktreesDir(repoPath string) error {
 	excludePath := filepath.Join(repoPath, ".git", "info", "exclude")
@@ -144,6 +218,33 @@ func IsWorktree(path string) bool {
 	return !info.IsDir()
 }
 
+// StateDir returns the sweatshop state directory for the worktree at path,
+// creating it if needed. It lives under the worktree's git admin dir so it
+// survives git clean and is removed along with the worktree.
+func StateDir(path string) (string, error) {
+	gitDir, err := git.GitDir(path)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(gitDir, StateDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// GlobalStateDir returns the directory for state shared by all worktrees,
+// such as the port registry. SWEATSHOP_STATE_DIR overrides the default
+// ~/.local/state/sweatshop.
+func GlobalStateDir() string {
+	if dir := os.Getenv("SWEATSHOP_STATE_DIR"); dir != "" {
+		return dir
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "sweatshop")
+}
+
 // FillBranchFromGit populates the Branch field from git.
 func (rp *ResolvedPath) FillBranchFromGit() error {
 	branch, err := git.BranchCurrent(rp.AbsPath)
@@ -3,6 +3,7 @@ package worktree
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -351,3 +352,31 @@ func TestExcludeWorktreesDirCreatesInfoDir(t *testing.T) {
 		t.Errorf("expected '.worktrees\\n', got %q", string(data))
 	}
 }
+
+func TestWorktreeAddArgs(t *testing.T) {
+	tests := []struct {
+		branch string
+		base   string
+		exists bool
+		want   []string
+	}{
+		{"feature/x", "", false, []string{"worktree", "add", "-b", "feature/x", "/wt"}},
+		{"feature/x", "origin/main", false, []string{"worktree", "add", "-b", "feature/x", "/wt", "origin/main"}},
+		{"feature/x", "", true, []string{"worktree", "add", "/wt", "feature/x"}},
+	}
+
+	for _, tt := range tests {
+		got, err := worktreeAddArgs("/wt", tt.branch, tt.base, tt.exists)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tt, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt, got, tt.want)
+		}
+	}
+
+	if _, err := worktreeAddArgs("/wt", "feature/x", "origin/main", true); err == nil {
+		t.Error("expected an error for an existing branch with a base")
+	}
+}
@@ -95,3 +95,82 @@ function perms_check_handles_empty_tiers_dir { # @test
 
   [[ -z "$result" ]]
 }
+
+function perms_check_denies_matching_deny_rule { # @test
+  mkdir -p "$PERMS_DIR"
+  cat >"$PERMS_DIR/global.json" <<'EOF'
+{
+  "allow": ["Bash(git *)"],
+  "deny": ["Bash(git push --force*)"]
+}
+EOF
+
+  local result
+  result=$(echo '{"tool_name":"Bash","tool_input":{"command":"git push --force"},"cwd":"'"$HOME"'/eng/worktrees/myrepo/feature"}' \
+    | SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms check)
+
+  [[ "$result" == *'"behavior":"deny"'* ]]
+  [[ "$result" == *"global tier"* ]]
+}
+
+function perms_lint_fix_removes_redundant_rules { # @test
+  mkdir -p "$PERMS_DIR/repos"
+  cat >"$PERMS_DIR/global.json" <<'EOF'
+{
+  "allow": ["Bash(git *)"]
+}
+EOF
+  cat >"$PERMS_DIR/repos/myrepo.json" <<'EOF'
+{
+  "allow": ["Bash(git status)", "Bash(make:*)"]
+}
+EOF
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms lint
+  [[ "$status" -ne 0 ]]
+  [[ "$output" == *"not ok 2 - myrepo allow Bash(git status)"* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms lint --fix
+  [[ "$status" -eq 0 ]]
+  [[ "$(cat "$PERMS_DIR/repos/myrepo.json")" != *"git status"* ]]
+}
+
+function perms_add_and_remove_edit_tiers { # @test
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms add 'Bash(make:*)' --repo myrepo
+  [[ "$status" -eq 0 ]]
+  [[ "$output" == *"added Bash(make:*) to myrepo allow"* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms move 'Bash(make:*)' --to global
+  [[ "$status" -eq 0 ]]
+  [[ "$(cat "$PERMS_DIR/global.json")" == *"Bash(make:*)"* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms remove 'Bash(make:*)'
+  [[ "$status" -eq 0 ]]
+  [[ "$(cat "$PERMS_DIR/global.json")" != *"Bash(make:*)"* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms add 'Bash()'
+  [[ "$status" -ne 0 ]]
+}
+
+function perms_toml_tier_records_reason_and_expiry { # @test
+  mkdir -p "$PERMS_DIR"
+  cat >"$PERMS_DIR/global.toml" <<'EOF'
+allow = [
+  "Read",
+  { rule = "Bash(make:*)", expires = 2020-01-01 },
+]
+EOF
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms explain 'Bash(make build)'
+  [[ "$status" -eq 0 ]]
+  [[ "$output" == *"none: no rule matches"* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms add 'Bash(ls)' --reason "listing" --expires 7d
+  [[ "$status" -eq 0 ]]
+  [[ "$(cat "$PERMS_DIR/global.toml")" == *'reason = "listing"'* ]]
+
+  run env SWEATSHOP_PERMS_DIR="$PERMS_DIR" sweatshop perms list
+  [[ "$status" -eq 0 ]]
+  [[ "$output" == *"Bash(ls)  # listing; expires"* ]]
+  [[ "$output" == *"Bash(make:*)  # expired 2020-01-01"* ]]
+}